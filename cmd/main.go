@@ -1,24 +1,75 @@
 // Package main is the entry point for the helmchart-helper CLI tool.
 //
 // It wires together the CLI flag parser (pkg/cli), production filesystem
-// implementations (pkg/filesystem), and the chart generator (pkg/app).
+// implementations (pkg/filesystem), the chart generator (pkg/app) and the
+// chart linter (pkg/lint).
 //
 // Execution flow:
 //  1. Parse CLI flags → handle --version/--help → validate required flags
 //  2. Create production dependencies (filesystem, template processor, path manager)
 //  3. Configure the App with enabled resource types from CLI flags
 //  4. Generate the Helm chart to the specified output directory
+//
+// The lint command (helmchart-helper lint <chart>) checks a generated chart
+// instead and exits with status 1 when it reports findings other than
+// warnings. The umbrella command (helmchart-helper umbrella -n name -o path
+// -component ...) generates a parent chart and one component chart per
+// -component under charts/. The
+// import command (helmchart-helper import -n name -o path manifests...)
+// generates a chart from existing Kubernetes manifests, the import-kustomize
+// command (helmchart-helper import-kustomize -n name -o path dir) from the
+// objects of a kustomization built offline, the from-compose command
+// (helmchart-helper from-compose -f docker-compose.yml -n name -o path) from
+// the services of a docker-compose file.
+//
+// The generator and the umbrella command record their version and arguments
+// in the .helmchart-helper.yaml lock file of the chart, the upgrade command
+// (helmchart-helper upgrade chart) regenerates the chart from them and merges
+// the changes of the current templates into the chart.
 package main
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
 	"github.com/sgaunet/helmchart-helper/pkg/app"
 	"github.com/sgaunet/helmchart-helper/pkg/cli"
+	"github.com/sgaunet/helmchart-helper/pkg/compose"
 	"github.com/sgaunet/helmchart-helper/pkg/filesystem"
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+	"github.com/sgaunet/helmchart-helper/pkg/kustomize"
+	"github.com/sgaunet/helmchart-helper/pkg/lint"
+	"github.com/sgaunet/helmchart-helper/pkg/upgrade"
 )
 
 var version = "dev"
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			runLint(os.Args[2:])
+			return
+		case "umbrella":
+			runUmbrella(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "import-kustomize":
+			runImportKustomize(os.Args[2:])
+			return
+		case "from-compose":
+			runFromCompose(os.Args[2:])
+			return
+		case "upgrade":
+			runUpgrade(os.Args[2:])
+			return
+		}
+	}
+
 	// Parse CLI flags
 	config, err := cli.ParseFlags()
 	if err != nil {
@@ -42,19 +93,333 @@ func main() {
 	
 	// Create and configure app
 	chartApp := app.NewApp(config.ChartName, config.OutputDir, fs, templateProcessor, pathManager, app.GetChartTemplate())
+	configureApp(chartApp, config)
+
+	// Generate chart
+	if err := chartApp.GenerateChart(); err != nil {
+		cli.ExitWithError(err)
+	}
+	lock, err := upgrade.NewLock(version, "", config.OutputDir, os.Args[1:])
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if err := upgrade.WriteLock(fs, config.OutputDir, lock); err != nil {
+		cli.ExitWithError(err)
+	}
+}
+
+// configureApp selects the resources of the chart from the CLI configuration.
+func configureApp(chartApp *app.App, config *cli.Config) {
 	chartApp.SetDeployment(config.Deployment)
 	chartApp.SetHpa(config.Hpa)
+	chartApp.SetKeda(config.Keda)
+	chartApp.SetVpa(config.Vpa)
 	chartApp.SetStatefulSet(config.StatefulSet)
 	chartApp.SetDaemonSet(config.DaemonSet)
 	chartApp.SetCronjob(config.Cronjob)
 	chartApp.SetConfigmap(config.Configmap)
+	chartApp.SetConfigFiles(config.ConfigFiles)
+	chartApp.SetConfigDir(config.ConfigDir)
 	chartApp.SetIngress(config.Ingress)
+	chartApp.SetCertificate(config.Certificate)
+	chartApp.SetHTTPRoute(config.HTTPRoute)
 	chartApp.SetVolumes(config.Volumes)
 	chartApp.SetService(config.Service)
 	chartApp.SetServiceAccount(config.ServiceAccount)
+	chartApp.SetMetrics(config.Metrics)
+	chartApp.SetProbe(config.ProbeType, config.ProbePath)
+	chartApp.SetPorts(config.Ports)
+	chartApp.SetZoneSpread(config.ZoneSpread)
+	chartApp.SetSecurityProfile(config.SecurityProfile)
+	chartApp.SetExtraObjects(config.ExtraObjects)
+	chartApp.SetDependencies(config.Dependencies)
+	chartApp.SetChartType(config.ChartType)
+	chartApp.SetLibrary(config.Library)
+	chartApp.SetEnvironments(config.Environments)
+	chartApp.SetEnvironmentProfile(config.EnvProfile)
+	chartApp.SetSidecars(config.Sidecars)
+	chartApp.SetDockerfile(config.FromDockerfile)
+}
 
-	// Generate chart
-	if err := chartApp.GenerateChart(); err != nil {
+// runUmbrella runs the umbrella command.
+func runUmbrella(args []string) {
+	config, err := cli.ParseUmbrellaFlagsFromArgs(args)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if config.Help {
+		cli.ExitSuccess()
+	}
+	if err := config.Validate(); err != nil {
+		cli.ExitWithError(err)
+	}
+
+	if err := generateUmbrella(config); err != nil {
+		cli.ExitWithError(err)
+	}
+	lock, err := upgrade.NewLock(version, "umbrella", config.OutputDir, args)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if err := upgrade.WriteLock(filesystem.NewOSFileSystem(), config.OutputDir, lock); err != nil {
+		cli.ExitWithError(err)
+	}
+}
+
+// generateUmbrella generates the umbrella chart and its components.
+func generateUmbrella(config *cli.UmbrellaConfig) error {
+	umbrella := app.NewUmbrella(config.ChartName, config.OutputDir, filesystem.NewOSFileSystem(),
+		filesystem.NewDefaultTemplateProcessor(), filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+	for _, component := range config.Components {
+		configureApp(umbrella.AddComponent(component.Name), component.Config)
+	}
+	return umbrella.GenerateChart()
+}
+
+// runImport runs the import command, the objects that are not imported in the
+// values of the chart resources are reported on stderr.
+func runImport(args []string) {
+	config, err := cli.ParseImportFlagsFromArgs(args)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if config.Help {
+		cli.ExitSuccess()
+	}
+	if err := config.Validate(); err != nil {
+		cli.ExitWithError(err)
+	}
+
+	chartImporter := importer.NewImporter(filesystem.NewOSFileSystem(), filesystem.NewDefaultTemplateProcessor(),
+		filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+	objects, err := chartImporter.ReadManifests(config.Sources, os.Stdin)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	warnings, err := chartImporter.Import(config.ChartName, config.OutputDir, objects)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, w)
+	}
+}
+
+// runImportKustomize runs the import-kustomize command, the unsupported fields
+// of the kustomizations and the objects that are not imported in the values
+// of the chart resources are reported on stderr.
+func runImportKustomize(args []string) {
+	config, err := cli.ParseKustomizeFlagsFromArgs(args)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if config.Help {
+		cli.ExitSuccess()
+	}
+	if err := config.Validate(); err != nil {
+		cli.ExitWithError(err)
+	}
+
+	fs := filesystem.NewOSFileSystem()
+	objects, warnings, err := kustomize.NewBuilder(fs).Build(config.Dir)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	chartImporter := importer.NewImporter(fs, filesystem.NewDefaultTemplateProcessor(),
+		filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+	importWarnings, err := chartImporter.Import(config.ChartName, config.OutputDir, objects)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	for _, w := range append(warnings, importWarnings...) {
+		fmt.Fprintln(os.Stderr, w)
+	}
+}
+
+// runFromCompose runs the from-compose command: a single service is converted
+// into the chart, several services into the component charts of an umbrella
+// chart.
+func runFromCompose(args []string) {
+	config, err := cli.ParseComposeFlagsFromArgs(args)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if config.Help {
+		cli.ExitSuccess()
+	}
+	if err := config.Validate(); err != nil {
+		cli.ExitWithError(err)
+	}
+
+	fs := filesystem.NewOSFileSystem()
+	project, err := compose.NewLoader(fs).Load(config.ComposeFile)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	charts, warnings, err := project.Convert(config.Services)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+
+	chartImporter := importer.NewImporter(fs, filesystem.NewDefaultTemplateProcessor(),
+		filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+	var importWarnings []string
+	if len(charts) == 1 {
+		charts[0].Name = config.ChartName
+		importWarnings, err = chartImporter.ImportChart(config.OutputDir, charts[0])
+	} else {
+		importWarnings, err = chartImporter.ImportUmbrella(config.ChartName, config.OutputDir, charts)
+	}
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	for _, w := range append(warnings, importWarnings...) {
+		fmt.Fprintln(os.Stderr, w)
+	}
+}
+
+// runUpgrade runs the upgrade command: the changes are reported on stderr,
+// and the command exits with status 1 when there are conflicts.
+func runUpgrade(args []string) {
+	config, err := cli.ParseUpgradeFlagsFromArgs(args)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	if config.Help {
+		cli.ExitSuccess()
+	}
+	if err := config.Validate(); err != nil {
+		cli.ExitWithError(err)
+	}
+
+	changes, err := upgradeChart(config)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	conflicts := 0
+	for _, c := range changes {
+		fmt.Fprintln(os.Stderr, c)
+		if c.Status == upgrade.Conflict {
+			conflicts++
+		}
+	}
+	if conflicts > 0 {
+		cli.ExitWithError(fmt.Errorf("%d conflict(s) in %s, resolve the conflict markers", conflicts, config.ChartDir))
+	}
+}
+
+// upgradeChart regenerates the base and the new chart from the lock file of
+// the chart in a scratch directory, merges them into the chart and records
+// the current version in the lock file.
+func upgradeChart(config *cli.UpgradeConfig) ([]upgrade.Change, error) {
+	fs := filesystem.NewOSFileSystem()
+	lock, err := upgrade.ReadLock(fs, config.ChartDir)
+	if err != nil {
+		return nil, err
+	}
+	scratch, err := os.MkdirTemp("", "helmchart-helper-upgrade-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	basePath, newPath := filepath.Join(scratch, "base"), filepath.Join(scratch, "new")
+	switch {
+	case config.Generator != "":
+		err = runGenerator(config.Generator, *lock, config.ChartDir, basePath)
+	case lock.Version == version:
+		err = generate(*lock, config.ChartDir, basePath)
+	default:
+		err = fmt.Errorf("the chart was generated by helmchart-helper %s, "+
+			"pass the binary of this version with -generator to regenerate its base", lock.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := generate(*lock, config.ChartDir, newPath); err != nil {
+		return nil, err
+	}
+
+	changes, err := upgrade.Merge(fs, config.ChartDir, basePath, newPath, "helmchart-helper "+version)
+	if err != nil {
+		return nil, err
+	}
+	lock.Version = version
+	return changes, upgrade.WriteLock(fs, config.ChartDir, *lock)
+}
+
+// generate generates the chart recorded in the lock of the chart in chartDir
+// in chartPath with the templates of this version.
+func generate(lock upgrade.Lock, chartDir, chartPath string) error {
+	args := append(lock.ResolveArgs(chartDir), "-o", chartPath)
+	switch lock.Command {
+	case "":
+		config, err := cli.ParseFlagsFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		chartApp := app.NewApp(config.ChartName, config.OutputDir, filesystem.NewOSFileSystem(),
+			filesystem.NewDefaultTemplateProcessor(), filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+		configureApp(chartApp, config)
+		return chartApp.GenerateChart()
+	case "umbrella":
+		config, err := cli.ParseUmbrellaFlagsFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		return generateUmbrella(config)
+	}
+	return fmt.Errorf("unknown command %q in the lock file", lock.Command)
+}
+
+// runGenerator generates the chart recorded in the lock of the chart in
+// chartDir in chartPath with the generator binary of another version.
+func runGenerator(generator string, lock upgrade.Lock, chartDir, chartPath string) error {
+	var args []string
+	if lock.Command != "" {
+		args = append(args, lock.Command)
+	}
+	args = append(append(args, lock.ResolveArgs(chartDir)...), "-o", chartPath)
+	cmd := exec.Command(generator, args...) //nolint:gosec // G204: the generator is given by the user
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", generator, err)
+	}
+	return nil
+}
+
+// runLint runs the lint command.
+func runLint(args []string) {
+	config, err := cli.ParseLintFlagsFromArgs(args)
+	if err != nil {
 		cli.ExitWithError(err)
 	}
+	if config.Help {
+		cli.ExitSuccess()
+	}
+	if err := config.Validate(); err != nil {
+		cli.ExitWithError(err)
+	}
+
+	linter := lint.NewLinter(filesystem.NewOSFileSystem(), filesystem.NewDefaultPathManager())
+	findings, err := linter.Lint(config.ChartDir, config.SecurityProfile)
+	if err != nil {
+		cli.ExitWithError(err)
+	}
+	violations := 0
+	for _, f := range findings {
+		fmt.Fprintln(os.Stderr, f)
+		if !f.Warning {
+			violations++
+		}
+	}
+	if violations > 0 {
+		cli.ExitWithError(fmt.Errorf("%d finding(s) in %s", violations, config.ChartDir))
+	}
 }
@@ -7,15 +7,25 @@
 //
 // Main Components:
 //   - App: Main application struct coordinating chart generation
+//   - Umbrella: Parent chart declaring one dependency per component chart,
+//     each component being generated by an App under charts/
 //   - options: Configuration for which Kubernetes resources to generate
 //   - chartTemplate: Embedded filesystem containing Helm chart templates
 //
 // Chart Generation Flow:
+//  0. Read the Dockerfile of the image when given: its EXPOSE ports and its
+//     HEALTHCHECK replace the default ports and probes, its USER, VOLUME and
+//     ENV fill the security context, the volumes and the env of values.yaml
 //  1. Create directory structure (chart root + templates/)
-//  2. Generate basic files (Chart.yaml, values.yaml, _helpers.tpl, .helmignore)
-//  3. Generate conditional resource files based on enabled options
-//  4. Generate NOTES.txt with context-aware content
-//  5. Replace "example" placeholder with actual chart name in all generated files
+//  2. Copy the configuration files into files/ when requested
+//  3. Generate basic files (Chart.yaml, values.yaml, _helpers.tpl, .helmignore)
+//     and the values-<env>.yaml overlays of the environments
+//  4. Generate conditional resource files based on enabled options (named
+//     templates in a library chart, includes of the library templates when the
+//     application uses one)
+//  5. Generate NOTES.txt with context-aware content (not for library charts)
+//  6. Replace "example" placeholder with actual chart name in all generated
+//     files (the copied files/ are left untouched)
 //
 // Adding New Resource Types:
 //  1. Add a bool field to the options struct
@@ -34,8 +44,10 @@ package app
 
 import (
 	"embed"
+	"maps"
 	"os"
-	"strings"
+	"path/filepath"
+	"slices"
 
 	"github.com/sgaunet/helmchart-helper/pkg/errors"
 	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
@@ -50,17 +62,247 @@ func GetChartTemplate() embed.FS {
 }
 
 type options struct {
-	ChartName      string
-	Deployment     bool
-	Cronjob        bool
-	StatefulSet    bool
-	DaemonSet      bool
-	Configmap      bool
-	Service        bool
-	Ingress        bool
-	Volumes        bool
-	Hpa            bool
-	ServiceAccount bool
+	ChartName       string
+	Deployment      bool
+	Cronjob         bool
+	StatefulSet     bool
+	DaemonSet       bool
+	Configmap       bool
+	Service         bool
+	Ingress         bool
+	HTTPRoute       bool
+	Certificate     bool
+	Volumes         bool
+	Hpa             bool
+	ServiceAccount  bool
+	Metrics         bool
+	Keda            bool
+	Vpa             bool
+	Sidecars        []Sidecar
+	ProbeType       string
+	ProbePath       string
+	Ports           []Port
+	ConfigFiles     []string
+	ConfigDir       string
+	ZoneSpread      bool
+	SecurityProfile string
+	ExtraObjects    bool
+	Dependencies    []Dependency
+	ChartType       string
+	Library         Dependency
+	Components      []string
+	Notes           []string
+	// Dockerfile holds the settings read from the Dockerfile of the image,
+	// nil without one.
+	Dockerfile *dockerfile
+	// userHosts are the ingress hosts of the environment profiles, kept
+	// verbatim by the placeholder replacement.
+	userHosts []string
+}
+
+// Port is a named port of the main container, the protocol defaults to TCP.
+type Port struct {
+	Name     string
+	Number   int
+	Protocol string
+}
+
+// defaultPort is the container port used when no port is given.
+var defaultPort = Port{Name: "http", Number: 80}
+
+// MetricsPort is the container port of the metrics endpoint, written as
+// metrics.port in values.yaml.
+const MetricsPort = 9090
+
+// Probe types supported for the default liveness and readiness probes.
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeExec = "exec"
+	ProbeGRPC = "grpc"
+)
+
+// ProbeTypes lists the supported probe types.
+var ProbeTypes = []string{ProbeHTTP, ProbeTCP, ProbeExec, ProbeGRPC}
+
+// Security profiles filling the security contexts to satisfy the matching Pod
+// Security Standard.
+const (
+	SecurityRestricted = "restricted"
+	SecurityBaseline   = "baseline"
+	SecurityNone       = "none"
+)
+
+// SecurityProfiles lists the supported security profiles.
+var SecurityProfiles = []string{SecurityRestricted, SecurityBaseline, SecurityNone}
+
+// Sidecar describes a container scaffolded next to the main container.
+// When MountPath is set, an emptyDir volume named after the sidecar is shared
+// with the main container at that path (e.g. for a log shipper).
+type Sidecar struct {
+	Name      string
+	Image     string
+	Port      int
+	MountPath string
+}
+
+// WorkloadKind returns the kind of the generated workload targeted by
+// autoscalers. When several workloads are generated, Deployment takes
+// precedence over StatefulSet, DaemonSet and CronJob.
+func (o options) WorkloadKind() string {
+	switch {
+	case o.Deployment:
+		return "Deployment"
+	case o.StatefulSet:
+		return "StatefulSet"
+	case o.DaemonSet:
+		return "DaemonSet"
+	case o.Cronjob:
+		return "CronJob"
+	default:
+		return "Deployment"
+	}
+}
+
+// ScaledObject reports whether a KEDA ScaledObject is generated to scale the
+// Deployment or StatefulSet.
+func (o options) ScaledObject() bool {
+	return o.Keda && (o.Deployment || o.StatefulSet)
+}
+
+// ScaledJob reports whether a KEDA ScaledJob is generated, it replaces the
+// CronJob when keda.enabled is set.
+func (o options) ScaledJob() bool {
+	return o.Keda && o.Cronjob
+}
+
+// SharedVolumeClaim reports whether the standalone PersistentVolumeClaim is
+// generated. A StatefulSet alone claims its volumes through volumeClaimTemplates,
+// so the shared claim is only needed by the other workload kinds.
+func (o options) SharedVolumeClaim() bool {
+	return o.Deployment || o.DaemonSet || o.Cronjob || !o.StatefulSet
+}
+
+// Probes reports whether a long-running workload is generated. Jobs run to
+// completion, so the CronJob and the ScaledJob do not get any probe.
+func (o options) Probes() bool {
+	return o.Deployment || o.StatefulSet || o.DaemonSet
+}
+
+// DefaultProbes reports whether the liveness and readiness probes are enabled
+// by default. The httpGet, tcpSocket and grpc probes target the default
+// container port, which only exists when ports are exposed.
+func (o options) DefaultProbes() bool {
+	return o.ExposesPorts() || o.ProbeType == ProbeExec
+}
+
+// ExposesPorts reports whether the main container declares ports, either
+// because a Service is generated or because ports were given explicitly.
+func (o options) ExposesPorts() bool {
+	return o.Service || len(o.Ports) > 0
+}
+
+// ContainerPorts returns the ports of the main container, defaulting to a
+// single http port.
+func (o options) ContainerPorts() []Port {
+	if len(o.Ports) == 0 {
+		return []Port{defaultPort}
+	}
+	return o.Ports
+}
+
+// DefaultPort returns the port targeted by the probes, the ingress and the
+// route.
+func (o options) DefaultPort() Port {
+	return o.ContainerPorts()[0]
+}
+
+// FilesConfigMap reports whether configuration files are copied into the
+// chart and served by a ConfigMap mounted in the workloads.
+func (o options) FilesConfigMap() bool {
+	return len(o.ConfigFiles) > 0 || o.ConfigDir != ""
+}
+
+// TmpVolume reports whether an emptyDir is mounted on /tmp, the restricted
+// profile makes the root filesystem read-only.
+func (o options) TmpVolume() bool {
+	return o.SecurityProfile == SecurityRestricted
+}
+
+// SidecarVolumes returns the sidecars sharing an emptyDir volume with the
+// main container.
+func (o options) SidecarVolumes() []Sidecar {
+	var sidecars []Sidecar
+	for _, s := range o.Sidecars {
+		if s.MountPath != "" {
+			sidecars = append(sidecars, s)
+		}
+	}
+	return sidecars
+}
+
+// Healthcheck returns the HEALTHCHECK of the image run by the exec probes,
+// nil when the probes are not exec probes or the image has none.
+func (o options) Healthcheck() *healthcheck {
+	if o.Dockerfile == nil || o.ProbeType != ProbeExec {
+		return nil
+	}
+	return o.Dockerfile.Healthcheck
+}
+
+// ImageUser returns the numeric non-root user of the image, nil when it is
+// unknown.
+func (o options) ImageUser() *imageUser {
+	if o.Dockerfile == nil {
+		return nil
+	}
+	return o.Dockerfile.User
+}
+
+// chartVolumes returns the mount paths of the volumes mounted by the chart in
+// the main container, by volume name.
+func (o options) chartVolumes() map[string]string {
+	volumes := map[string]string{}
+	if o.TmpVolume() {
+		volumes["tmp"] = "/tmp"
+	}
+	if o.StatefulSet && o.Volumes {
+		volumes["data"] = "/data"
+	}
+	if o.FilesConfigMap() {
+		volumes["config-files"] = "/etc/" + o.ChartName
+	}
+	return volumes
+}
+
+// ImageVolumes returns the VOLUME mount points of the image mounted from an
+// emptyDir. The mount points of the chart volumes are left out and the volumes
+// named after a chart volume are prefixed with image-.
+func (o options) ImageVolumes() []imageVolume {
+	if o.Dockerfile == nil {
+		return nil
+	}
+	chartVolumes := o.chartVolumes()
+	var volumes []imageVolume
+	for _, v := range o.Dockerfile.Volumes {
+		if slices.Contains(slices.Collect(maps.Values(chartVolumes)), v.MountPath) {
+			continue
+		}
+		if _, ok := chartVolumes[v.Name]; ok {
+			v.Name = "image-" + v.Name
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes
+}
+
+// ImageEnv returns the ENV variables of the image, the defaults of the env
+// values.
+func (o options) ImageEnv() []envVar {
+	if o.Dockerfile == nil {
+		return nil
+	}
+	return o.Dockerfile.Env
 }
 
 // App manages Helm chart generation with configurable options.
@@ -71,6 +313,9 @@ type App struct {
 	templateProcessor interfaces.TemplateProcessor
 	pathManager       interfaces.PathManager
 	chartTemplateFS   embed.FS
+	environments      []string
+	envProfile        string
+	dockerfilePath    string
 }
 
 // NewApp creates a new application instance for generating Helm charts.
@@ -82,7 +327,11 @@ func NewApp(chartName string, chartPath string, fs interfaces.FileSystem, templa
 		pathManager:       pathManager,
 		chartTemplateFS:   chartTemplateFS,
 		opts: options{
-			ChartName: chartName,
+			ChartName:       chartName,
+			ProbeType:       ProbeHTTP,
+			ProbePath:       "/",
+			SecurityProfile: SecurityNone,
+			ChartType:       ChartTypeApplication,
 		},
 	}
 }
@@ -117,6 +366,17 @@ func (a *App) SetIngress(v bool) {
 	a.opts.Ingress = v
 }
 
+// SetHTTPRoute enables or disables Gateway API HTTPRoute resource generation.
+func (a *App) SetHTTPRoute(v bool) {
+	a.opts.HTTPRoute = v
+}
+
+// SetCertificate enables or disables cert-manager Certificate generation for
+// the ingress hosts.
+func (a *App) SetCertificate(v bool) {
+	a.opts.Certificate = v
+}
+
 // SetVolumes enables or disables Volumes resource generation.
 func (a *App) SetVolumes(v bool) {
 	a.opts.Volumes = v
@@ -132,6 +392,122 @@ func (a *App) SetServiceAccount(v bool) {
 	a.opts.ServiceAccount = v
 }
 
+// SetMetrics enables or disables the metrics port and the ServiceMonitor
+// (or PodMonitor when no Service is generated).
+func (a *App) SetMetrics(v bool) {
+	a.opts.Metrics = v
+}
+
+// SetKeda enables or disables KEDA autoscaling: a ScaledObject for the
+// Deployment/StatefulSet and a ScaledJob in place of the CronJob.
+func (a *App) SetKeda(v bool) {
+	a.opts.Keda = v
+}
+
+// SetVpa enables or disables VerticalPodAutoscaler resource generation.
+func (a *App) SetVpa(v bool) {
+	a.opts.Vpa = v
+}
+
+// SetExtraObjects enables or disables the extra-manifests.yaml template
+// rendering the extraObjects values through tpl.
+func (a *App) SetExtraObjects(v bool) {
+	a.opts.ExtraObjects = v
+}
+
+// SetNotes sets the notes appended to the list of the created objects in
+// NOTES.txt.
+func (a *App) SetNotes(notes []string) {
+	a.opts.Notes = notes
+}
+
+// SetDependencies sets the external charts declared in the dependencies of
+// Chart.yaml.
+func (a *App) SetDependencies(dependencies []Dependency) {
+	a.opts.Dependencies = dependencies
+}
+
+// SetChartType sets the type of the chart, application or library. Empty
+// values keep the default (application).
+func (a *App) SetChartType(chartType string) {
+	if chartType != "" {
+		a.opts.ChartType = chartType
+	}
+}
+
+// SetLibrary sets the library chart whose named templates are included by the
+// resources of the application.
+func (a *App) SetLibrary(library Dependency) {
+	a.opts.Library = library
+}
+
+// SetEnvironments sets the environments whose values-<env>.yaml overlay is
+// generated.
+func (a *App) SetEnvironments(environments []string) {
+	a.environments = environments
+}
+
+// SetEnvironmentProfile sets the YAML file overriding the built-in environment
+// profiles.
+func (a *App) SetEnvironmentProfile(path string) {
+	a.envProfile = path
+}
+
+// SetDockerfile sets the Dockerfile of the image whose EXPOSE, HEALTHCHECK,
+// USER, VOLUME and ENV instructions fill the options left to their defaults
+// and the values.
+func (a *App) SetDockerfile(path string) {
+	a.dockerfilePath = path
+}
+
+// SetProbe sets the type (http, tcp, exec or grpc) and the HTTP path of the
+// default liveness and readiness probes. Empty values keep the defaults.
+func (a *App) SetProbe(probeType, path string) {
+	if probeType != "" {
+		a.opts.ProbeType = probeType
+	}
+	if path != "" {
+		a.opts.ProbePath = path
+	}
+}
+
+// SetPorts sets the named ports of the main container. The first port is the
+// default one.
+func (a *App) SetPorts(ports []Port) {
+	a.opts.Ports = ports
+}
+
+// SetConfigFiles sets the local files copied into the files/ directory of the
+// chart.
+func (a *App) SetConfigFiles(files []string) {
+	a.opts.ConfigFiles = files
+}
+
+// SetConfigDir sets the local directory whose files are copied into the
+// files/ directory of the chart.
+func (a *App) SetConfigDir(dir string) {
+	a.opts.ConfigDir = dir
+}
+
+// SetZoneSpread enables or disables the default topology spread constraint
+// spreading the pods across zones.
+func (a *App) SetZoneSpread(v bool) {
+	a.opts.ZoneSpread = v
+}
+
+// SetSecurityProfile sets the security profile (restricted, baseline or none)
+// used to fill the security contexts. An empty value keeps the default (none).
+func (a *App) SetSecurityProfile(profile string) {
+	if profile != "" {
+		a.opts.SecurityProfile = profile
+	}
+}
+
+// SetSidecars sets the sidecar containers scaffolded in the workloads.
+func (a *App) SetSidecars(sidecars []Sidecar) {
+	a.opts.Sidecars = sidecars
+}
+
 // SetStatefulSet enables or disables StatefulSet resource generation.
 func (a *App) SetStatefulSet(v bool) {
 	a.opts.StatefulSet = v
@@ -139,14 +515,26 @@ func (a *App) SetStatefulSet(v bool) {
 
 // GenerateChart generates the complete Helm chart with all configured resources.
 func (a *App) GenerateChart() error {
+	if err := a.readDockerfile(); err != nil {
+		return err
+	}
+	
 	if err := a.createDirectoryStructure(); err != nil {
 		return err
 	}
 	
+	if err := a.copyConfigFiles(); err != nil {
+		return err
+	}
+	
 	if err := a.generateBasicFiles(); err != nil {
 		return err
 	}
 	
+	if err := a.generateEnvironmentFiles(); err != nil {
+		return err
+	}
+	
 	if err := a.generateConditionalFiles(); err != nil {
 		return err
 	}
@@ -173,7 +561,7 @@ func (a *App) createDirectoryStructure() error {
 			WithFile(templatesDir)
 	}
 	
-	if a.opts.Service {
+	if a.opts.Service && !a.opts.IsLibrary() {
 		testsDir := a.pathManager.Join(a.chartPath, "templates", "tests")
 		err = a.fs.MkdirAll(testsDir, dirPerm)
 		if err != nil {
@@ -199,7 +587,14 @@ func (a *App) generateBasicFiles() error {
 	if err != nil {
 		return err
 	}
-	err = a.copyFileFromTemplate("chartTemplate/helmignore", a.pathManager.Join(a.chartPath, ".helmignore"))
+	if a.opts.Cronjob && !a.opts.UsesLibrary() {
+		// pod template of the CronJob and of the ScaledJob
+		err = a.createFileFromTemplate("chartTemplate/templates/job.tpl", a.pathManager.Join(a.chartPath, "templates", "_job.tpl"))
+		if err != nil {
+			return err
+		}
+	}
+	err = a.createFileFromTemplate("chartTemplate/helmignore", a.pathManager.Join(a.chartPath, ".helmignore"))
 	if err != nil {
 		return err
 	}
@@ -227,18 +622,38 @@ func (a *App) generateConditionalFiles() error {
 		{a.opts.DaemonSet, "chartTemplate/templates/daemonset.yaml", a.pathManager.Join(a.chartPath, "templates", "daemonset.yaml")},
 		{a.opts.Service, "chartTemplate/templates/service.yaml", a.pathManager.Join(a.chartPath, "templates", "service.yaml")},
 		{a.opts.Ingress, "chartTemplate/templates/ingress.yaml", a.pathManager.Join(a.chartPath, "templates", "ingress.yaml")},
+		{a.opts.Certificate, "chartTemplate/templates/certificate.yaml", a.pathManager.Join(a.chartPath, "templates", "certificate.yaml")},
+		{a.opts.HTTPRoute, "chartTemplate/templates/httproute.yaml", a.pathManager.Join(a.chartPath, "templates", "httproute.yaml")},
 		{a.opts.Configmap, "chartTemplate/templates/configmap.yaml", a.pathManager.Join(a.chartPath, "templates", "configmap.yaml")},
+		{a.opts.FilesConfigMap(), "chartTemplate/templates/configmap-files.yaml", a.pathManager.Join(a.chartPath, "templates", "configmap-files.yaml")},
 		{a.opts.ServiceAccount, "chartTemplate/templates/serviceaccount.yaml", a.pathManager.Join(a.chartPath, "templates", "serviceaccount.yaml")},
 		{a.opts.StatefulSet, "chartTemplate/templates/statefulset.yaml", a.pathManager.Join(a.chartPath, "templates", "statefulset.yaml")},
+		{a.opts.StatefulSet, "chartTemplate/templates/service-headless.yaml", a.pathManager.Join(a.chartPath, "templates", "service-headless.yaml")},
 		{a.opts.Hpa, "chartTemplate/templates/hpa.yaml", a.pathManager.Join(a.chartPath, "templates", "hpa.yaml")},
-		{a.opts.Volumes, "chartTemplate/templates/pvc.yaml", a.pathManager.Join(a.chartPath, "templates", "pvc.yaml")},
+		{a.opts.Vpa, "chartTemplate/templates/vpa.yaml", a.pathManager.Join(a.chartPath, "templates", "vpa.yaml")},
+		{a.opts.ScaledObject(), "chartTemplate/templates/scaledobject.yaml", a.pathManager.Join(a.chartPath, "templates", "scaledobject.yaml")},
+		{a.opts.ScaledJob(), "chartTemplate/templates/scaledjob.yaml", a.pathManager.Join(a.chartPath, "templates", "scaledjob.yaml")},
+		{a.opts.Volumes && a.opts.SharedVolumeClaim(), "chartTemplate/templates/pvc.yaml", a.pathManager.Join(a.chartPath, "templates", "pvc.yaml")},
+		{a.opts.Metrics && a.opts.Service, "chartTemplate/templates/servicemonitor.yaml", a.pathManager.Join(a.chartPath, "templates", "servicemonitor.yaml")},
+		{a.opts.Metrics && !a.opts.Service, "chartTemplate/templates/podmonitor.yaml", a.pathManager.Join(a.chartPath, "templates", "podmonitor.yaml")},
+		{a.opts.ExtraObjects, "chartTemplate/templates/extra-manifests.yaml", a.pathManager.Join(a.chartPath, "templates", "extra-manifests.yaml")},
 	}
 	
 	for _, resource := range resources {
-		if resource.enabled {
-			if err := a.createFileFromTemplate(resource.template, resource.outputFile); err != nil {
-				return err
-			}
+		if !resource.enabled {
+			continue
+		}
+		var err error
+		switch {
+		case a.opts.IsLibrary():
+			err = a.createDefineFromTemplate(resource.template, resource.outputFile)
+		case a.opts.UsesLibrary():
+			err = a.createLibraryInclude(resource.outputFile)
+		default:
+			err = a.createFileFromTemplate(resource.template, resource.outputFile)
+		}
+		if err != nil {
+			return err
 		}
 	}
 	
@@ -246,6 +661,10 @@ func (a *App) generateConditionalFiles() error {
 }
 
 func (a *App) generateNotesFiles() error {
+	if a.opts.IsLibrary() {
+		// library charts are not installed
+		return nil
+	}
 	notesPath := a.pathManager.Join(a.chartPath, "templates", "NOTES.txt")
 	err := a.createFileFromTemplate("chartTemplate/templates/NOTES-objects-created.txt", notesPath)
 	if err != nil {
@@ -255,12 +674,25 @@ func (a *App) generateNotesFiles() error {
 	if err != nil {
 		return err
 	}
+	if a.opts.Ingress || a.opts.HTTPRoute {
+		// one header for the URLs of the ingress and of the route
+		err = a.appendToFile("chartTemplate/templates/NOTES-URL.txt", notesPath)
+		if err != nil {
+			return err
+		}
+	}
 	if a.opts.Ingress {
 		err = a.appendToFile("chartTemplate/templates/NOTES-INGRESS.txt", notesPath)
 		if err != nil {
 			return err
 		}
 	}
+	if a.opts.HTTPRoute {
+		err = a.appendToFile("chartTemplate/templates/NOTES-HTTPROUTE.txt", notesPath)
+		if err != nil {
+			return err
+		}
+	}
 	if a.opts.Service {
 		err = a.appendToFile("chartTemplate/templates/NOTES-SERVICE.txt", notesPath)
 		if err != nil {
@@ -276,6 +708,10 @@ func (a *App) replaceTemplatePlaceholders() error {
 }
 
 func (a *App) createFileFromTemplate(templatePath string, outputPath string) error {
+	return a.createFileFromTemplateData(templatePath, outputPath, a.opts)
+}
+
+func (a *App) createFileFromTemplateData(templatePath string, outputPath string, data any) error {
 	outputFile, err := a.fs.Create(outputPath)
 	if err != nil {
 		return errors.NewFileSystemError("create-file", "failed to create output file", err).
@@ -294,7 +730,7 @@ func (a *App) createFileFromTemplate(templatePath string, outputPath string) err
 			WithFile(templatePath)
 	}
 	
-	err = tmpl.Execute(outputFile, a.opts)
+	err = tmpl.Execute(outputFile, data)
 	if err != nil {
 		return errors.NewTemplateError("execute-template", "failed to execute template", err).
 			WithChart(a.opts.ChartName).
@@ -359,11 +795,16 @@ func (a *App) appendToFile(templatePath string, outputPath string) error {
 // only regular files. Each file is read, modified in-memory, and written back
 // with a file permission of 0644.
 func (a *App) replaceExampleInAllFiles(path string) error {
+	filesDir := a.pathManager.Join(path, configFilesDir)
 	err := a.fs.Walk(path, func(p string, info os.FileInfo, erR error) error {
 		if erR != nil {
 			return erR
 		}
 		if info.IsDir() {
+			// user provided configuration files are copied verbatim
+			if p == filesDir {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		read, err := a.fs.ReadFile(p)
@@ -372,7 +813,7 @@ func (a *App) replaceExampleInAllFiles(path string) error {
 				WithChart(a.opts.ChartName).
 				WithFile(p)
 		}
-		newContents := strings.ReplaceAll(string(read), "example", a.opts.ChartName)
+		newContents := a.opts.replacePlaceholder(string(read))
 		const filePerm = 0644
 		if err = a.fs.WriteFile(p, []byte(newContents), filePerm); err != nil {
 			return errors.NewFileSystemError("replace-placeholder", "failed to write file", err).
@@ -2,6 +2,7 @@ package app
 
 import (
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -168,6 +169,19 @@ func TestApp_generateConditionalFiles(t *testing.T) {
 				"test-path/templates/ingress.yaml",
 			},
 		},
+		{
+			name: "httproute file generation",
+			opts: options{
+				ChartName: "test-chart",
+				Service:   true,
+				HTTPRoute: true,
+			},
+			wantErr: false,
+			expectedFiles: []string{
+				"test-path/templates/service.yaml",
+				"test-path/templates/httproute.yaml",
+			},
+		},
 		{
 			name: "volumes file generation",
 			opts: options{
@@ -179,6 +193,69 @@ func TestApp_generateConditionalFiles(t *testing.T) {
 				"test-path/templates/pvc.yaml",
 			},
 		},
+		{
+			name: "metrics with service generates servicemonitor",
+			opts: options{
+				ChartName:  "test-chart",
+				Deployment: true,
+				Service:    true,
+				Metrics:    true,
+			},
+			wantErr: false,
+			expectedFiles: []string{
+				"test-path/templates/servicemonitor.yaml",
+			},
+		},
+		{
+			name: "metrics without service generates podmonitor",
+			opts: options{
+				ChartName: "test-chart",
+				DaemonSet: true,
+				Metrics:   true,
+			},
+			wantErr: false,
+			expectedFiles: []string{
+				"test-path/templates/podmonitor.yaml",
+			},
+		},
+		{
+			name: "statefulset generates headless service",
+			opts: options{
+				ChartName:   "test-chart",
+				StatefulSet: true,
+			},
+			wantErr: false,
+			expectedFiles: []string{
+				"test-path/templates/statefulset.yaml",
+				"test-path/templates/service-headless.yaml",
+			},
+		},
+		{
+			name: "vpa file generation",
+			opts: options{
+				ChartName: "test-chart",
+				DaemonSet: true,
+				Vpa:       true,
+			},
+			wantErr: false,
+			expectedFiles: []string{
+				"test-path/templates/daemonset.yaml",
+				"test-path/templates/vpa.yaml",
+			},
+		},
+		{
+			name: "certificate file generation",
+			opts: options{
+				ChartName:   "test-chart",
+				Ingress:     true,
+				Certificate: true,
+			},
+			wantErr: false,
+			expectedFiles: []string{
+				"test-path/templates/ingress.yaml",
+				"test-path/templates/certificate.yaml",
+			},
+		},
 		{
 			name: "no conditional files",
 			opts: options{
@@ -225,6 +302,251 @@ func TestApp_generateConditionalFiles(t *testing.T) {
 	}
 }
 
+func TestApp_generateConditionalFiles_statefulSetVolumes(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options
+		wantPVC bool
+	}{
+		{
+			name:    "statefulset alone uses volumeClaimTemplates",
+			opts:    options{ChartName: "test-chart", StatefulSet: true, Volumes: true},
+			wantPVC: false,
+		},
+		{
+			name:    "statefulset with deployment keeps the shared claim",
+			opts:    options{ChartName: "test-chart", StatefulSet: true, Deployment: true, Volumes: true},
+			wantPVC: true,
+		},
+		{
+			name:    "volumes without workload keeps the shared claim",
+			opts:    options{ChartName: "test-chart", Volumes: true},
+			wantPVC: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFS := mocks.NewMockFileSystem()
+			app := newTestApp(mockFS, mocks.NewMockTemplateProcessor(), tt.opts)
+
+			if err := app.generateConditionalFiles(); err != nil {
+				t.Fatalf("generateConditionalFiles() error = %v", err)
+			}
+
+			_, exists := mockFS.Files["test-path/templates/pvc.yaml"]
+			if exists != tt.wantPVC {
+				t.Errorf("pvc.yaml generated = %v, want %v", exists, tt.wantPVC)
+			}
+		})
+	}
+}
+
+func TestOptions_WorkloadKind(t *testing.T) {
+	tests := []struct {
+		name string
+		opts options
+		want string
+	}{
+		{name: "deployment", opts: options{Deployment: true}, want: "Deployment"},
+		{name: "statefulset", opts: options{StatefulSet: true}, want: "StatefulSet"},
+		{name: "deployment takes precedence", opts: options{Deployment: true, StatefulSet: true}, want: "Deployment"},
+		{name: "daemonset", opts: options{DaemonSet: true}, want: "DaemonSet"},
+		{name: "cronjob", opts: options{Cronjob: true}, want: "CronJob"},
+		{name: "cronjob with keda", opts: options{Cronjob: true, Keda: true}, want: "CronJob"},
+		{name: "daemonset with scaledjob", opts: options{DaemonSet: true, Cronjob: true, Keda: true}, want: "DaemonSet"},
+		{name: "no workload", opts: options{}, want: "Deployment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.WorkloadKind(); got != tt.want {
+				t.Errorf("WorkloadKind() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_ImageVolumes(t *testing.T) {
+	image := &dockerfile{Volumes: []imageVolume{
+		{Name: "tmp", MountPath: "/tmp"},
+		{Name: "data", MountPath: "/data"},
+		{Name: "config-files", MountPath: "/config/files"},
+		{Name: "cache", MountPath: "/cache"},
+	}}
+	tests := []struct {
+		name string
+		opts options
+		want []imageVolume
+	}{
+		{
+			name: "no chart volume",
+			opts: options{ChartName: "test-chart", Deployment: true, Dockerfile: image},
+			want: image.Volumes,
+		},
+		{
+			name: "chart volumes",
+			opts: options{
+				ChartName: "test-chart", StatefulSet: true, Volumes: true,
+				SecurityProfile: SecurityRestricted, ConfigFiles: []string{"app.conf"}, Dockerfile: image,
+			},
+			want: []imageVolume{
+				{Name: "image-config-files", MountPath: "/config/files"},
+				{Name: "cache", MountPath: "/cache"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.ImageVolumes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImageVolumes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_DefaultProbes(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        options
+		wantProbes  bool
+		wantDefault bool
+	}{
+		{name: "deployment with service", opts: options{Deployment: true, Service: true, ProbeType: ProbeHTTP}, wantProbes: true, wantDefault: true},
+		{name: "deployment without service", opts: options{Deployment: true, ProbeType: ProbeHTTP}, wantProbes: true, wantDefault: false},
+		{name: "exec probe without service", opts: options{DaemonSet: true, ProbeType: ProbeExec}, wantProbes: true, wantDefault: true},
+		{name: "cronjob", opts: options{Cronjob: true, Service: true, ProbeType: ProbeHTTP}, wantProbes: false, wantDefault: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Probes(); got != tt.wantProbes {
+				t.Errorf("Probes() = %v, want %v", got, tt.wantProbes)
+			}
+			if got := tt.opts.DefaultProbes(); got != tt.wantDefault {
+				t.Errorf("DefaultProbes() = %v, want %v", got, tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestOptions_ContainerPorts(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        options
+		wantExposed bool
+		wantDefault Port
+	}{
+		{name: "service with default port", opts: options{Service: true}, wantExposed: true, wantDefault: Port{Name: "http", Number: 80}},
+		{name: "no service no port", opts: options{Deployment: true}, wantExposed: false, wantDefault: Port{Name: "http", Number: 80}},
+		{name: "explicit ports", opts: options{Ports: []Port{{Name: "grpc", Number: 9000}, {Name: "http", Number: 8080}}}, wantExposed: true, wantDefault: Port{Name: "grpc", Number: 9000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.ExposesPorts(); got != tt.wantExposed {
+				t.Errorf("ExposesPorts() = %v, want %v", got, tt.wantExposed)
+			}
+			if got := tt.opts.DefaultPort(); got != tt.wantDefault {
+				t.Errorf("DefaultPort() = %+v, want %+v", got, tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestOptions_DependencyValues(t *testing.T) {
+	opts := options{Dependencies: []Dependency{
+		{Name: "postgresql", Repository: "oci://registry", Version: "15.5.0", Condition: "postgresql.enabled"},
+		{Name: "redis-cache", Repository: "https://charts", Version: "19.0.0", Condition: "cache.redis.enabled"},
+	}}
+	want := `
+# -- values of the postgresql chart (oci://registry)
+postgresql:
+  # -- deploy the postgresql dependency
+  enabled: true
+
+# -- values of the redis-cache chart (https://charts)
+redis-cache: {}
+
+cache:
+  redis:
+    # -- deploy the redis-cache dependency
+    enabled: true`
+	if got := opts.DependencyValues(); got != want {
+		t.Errorf("DependencyValues() = %q, want %q", got, want)
+	}
+	if got := opts.Dependencies[1].EnvName(); got != "REDIS_CACHE_HOST" {
+		t.Errorf("EnvName() = %q, want %q", got, "REDIS_CACHE_HOST")
+	}
+}
+
+func TestOptions_replacePlaceholder(t *testing.T) {
+	opts := options{
+		ChartName: "shop",
+		Library:   Dependency{Name: "lib", Repository: "oci://registry.example.com", Version: "1.0.0"},
+		Dependencies: []Dependency{
+			{Name: "redis", Repository: "oci://registry.example.com/charts", Version: "19.0.0", Condition: "redis.enabled"},
+		},
+		Sidecars: []Sidecar{{Name: "log", Image: "example/log:1"}},
+	}
+	content := "name: example\nrepository: oci://registry.example.com\nrepository: oci://registry.example.com/charts\nimage: example/log:1\nhost: chart-example.local\n"
+	want := "name: shop\nrepository: oci://registry.example.com\nrepository: oci://registry.example.com/charts\nimage: example/log:1\nhost: chart-shop.local\n"
+	if got := opts.replacePlaceholder(content); got != want {
+		t.Errorf("replacePlaceholder() = %q, want %q", got, want)
+	}
+}
+
+func TestApp_generateConditionalFiles_keda(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        options
+		wantFiles   []string
+		absentFiles []string
+	}{
+		{
+			name:        "scaledobject for deployment",
+			opts:        options{ChartName: "test-chart", Deployment: true, Keda: true},
+			wantFiles:   []string{"test-path/templates/deployment.yaml", "test-path/templates/scaledobject.yaml"},
+			absentFiles: []string{"test-path/templates/scaledjob.yaml"},
+		},
+		{
+			name:        "scaledjob with cronjob",
+			opts:        options{ChartName: "test-chart", Cronjob: true, Keda: true},
+			wantFiles:   []string{"test-path/templates/cronjob.yaml", "test-path/templates/scaledjob.yaml"},
+			absentFiles: []string{"test-path/templates/scaledobject.yaml"},
+		},
+		{
+			name:        "cronjob without keda",
+			opts:        options{ChartName: "test-chart", Cronjob: true},
+			wantFiles:   []string{"test-path/templates/cronjob.yaml"},
+			absentFiles: []string{"test-path/templates/scaledjob.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFS := mocks.NewMockFileSystem()
+			app := newTestApp(mockFS, mocks.NewMockTemplateProcessor(), tt.opts)
+
+			if err := app.generateConditionalFiles(); err != nil {
+				t.Fatalf("generateConditionalFiles() error = %v", err)
+			}
+
+			for _, f := range tt.wantFiles {
+				if _, exists := mockFS.Files[f]; !exists {
+					t.Errorf("Expected file %s was not created", f)
+				}
+			}
+			for _, f := range tt.absentFiles {
+				if _, exists := mockFS.Files[f]; exists {
+					t.Errorf("Unexpected file %s was created", f)
+				}
+			}
+		})
+	}
+}
+
 func TestApp_replaceTemplatePlaceholders(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -520,9 +842,9 @@ func TestApp_generateBasicFiles_errors(t *testing.T) {
 			},
 		},
 		{
-			name: "helmignore copy fails",
+			name: "helmignore creation fails",
 			setupErr: func(_ *mocks.MockFileSystem, tp *mocks.MockTemplateProcessor) {
-				tp.Errors["ReadFile:chartTemplate/helmignore"] = errors.New("read error")
+				tp.Errors["ParseFS:chartTemplate/helmignore"] = errors.New("invalid template")
 			},
 		},
 		{
@@ -613,6 +935,13 @@ func TestApp_generateNotesFiles_errors(t *testing.T) {
 				tp.Errors["ReadFile:chartTemplate/templates/NOTES-DEFAULT.txt"] = errors.New("read error")
 			},
 		},
+		{
+			name: "NOTES-URL.txt append fails with httproute enabled",
+			opts: options{ChartName: "test-chart", HTTPRoute: true},
+			setupErr: func(_ *mocks.MockFileSystem, tp *mocks.MockTemplateProcessor) {
+				tp.Errors["ReadFile:chartTemplate/templates/NOTES-URL.txt"] = errors.New("read error")
+			},
+		},
 		{
 			name: "NOTES-INGRESS.txt append fails with ingress enabled",
 			opts: options{ChartName: "test-chart", Ingress: true},
@@ -620,6 +949,13 @@ func TestApp_generateNotesFiles_errors(t *testing.T) {
 				tp.Errors["ReadFile:chartTemplate/templates/NOTES-INGRESS.txt"] = errors.New("read error")
 			},
 		},
+		{
+			name: "NOTES-HTTPROUTE.txt append fails with httproute enabled",
+			opts: options{ChartName: "test-chart", HTTPRoute: true},
+			setupErr: func(_ *mocks.MockFileSystem, tp *mocks.MockTemplateProcessor) {
+				tp.Errors["ReadFile:chartTemplate/templates/NOTES-HTTPROUTE.txt"] = errors.New("read error")
+			},
+		},
 		{
 			name: "NOTES-SERVICE.txt append fails with service enabled",
 			opts: options{ChartName: "test-chart", Service: true},
@@ -756,4 +1092,87 @@ func TestApp_GenerateChart_errors(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestApp_resolveEnvironments(t *testing.T) {
+	const profile = `
+prod:
+  ingressHost: "{chart}.company.com"
+  autoscaling:
+    maxReplicas: 20
+qa:
+  replicaCount: 1
+  ingressHost: "{chart}-{env}.local"
+broken:
+  autoscaling:
+    enabled: true
+`
+	tests := []struct {
+		name         string
+		environments []string
+		wantHosts    []string
+		errContains  string
+	}{
+		{
+			name:         "built-in profiles",
+			environments: []string{"dev", "staging"},
+			wantHosts:    []string{"test-chart.dev.local", "test-chart.staging.local"},
+		},
+		{
+			name:         "profile file",
+			environments: []string{"prod", "qa"},
+			wantHosts:    []string{"test-chart.company.com", "test-chart-qa.local"},
+		},
+		{
+			name:         "unknown environment",
+			environments: []string{"perf"},
+			errContains:  `no profile for environment "perf"`,
+		},
+		{
+			name:         "invalid autoscaling",
+			environments: []string{"broken"},
+			errContains:  "needs 1 <= minReplicas <= maxReplicas",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFS := mocks.NewMockFileSystem()
+			mockFS.Files["profile.yaml"] = []byte(profile)
+			app := newTestApp(mockFS, mocks.NewMockTemplateProcessor(), options{ChartName: "test-chart"})
+			app.SetEnvironments(tt.environments)
+			app.SetEnvironmentProfile("profile.yaml")
+
+			environments, err := app.resolveEnvironments()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("resolveEnvironments() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveEnvironments() error = %v", err)
+			}
+			var hosts []string
+			for _, env := range environments {
+				hosts = append(hosts, env.Host("test-chart"))
+			}
+			if !reflect.DeepEqual(hosts, tt.wantHosts) {
+				t.Errorf("hosts = %v, want %v", hosts, tt.wantHosts)
+			}
+		})
+	}
+
+	// the profile overrides only the fields it sets
+	mockFS := mocks.NewMockFileSystem()
+	mockFS.Files["profile.yaml"] = []byte(profile)
+	app := newTestApp(mockFS, mocks.NewMockTemplateProcessor(), options{ChartName: "test-chart"})
+	app.SetEnvironments([]string{"prod"})
+	app.SetEnvironmentProfile("profile.yaml")
+	environments, err := app.resolveEnvironments()
+	if err != nil {
+		t.Fatalf("resolveEnvironments() error = %v", err)
+	}
+	if got := environments[0]; got.ReplicaCount != 3 || got.Autoscaling.MinReplicas != 3 || got.Autoscaling.MaxReplicas != 20 {
+		t.Errorf("prod = %+v, want the built-in profile with maxReplicas 20", got)
+	}
+}
@@ -40,6 +40,9 @@ helm.sh/chart: {{ include "example.chart" . }}
 app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
 {{- end }}
 app.kubernetes.io/managed-by: {{ .Release.Service }}
+{{- with (.Values.global | default dict).labels }}
+{{ toYaml . }}
+{{- end }}
 {{- end }}
 
 {{/*
@@ -50,6 +53,144 @@ app.kubernetes.io/name: {{ include "example.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end }}
 
+{{/*
+Image of the main container, prefixed with global.imageRegistry when set
+(shared by the components of an umbrella chart)
+*/}}
+{{- define "example.image" -}}
+{{- $image := printf "%s:%s" .Values.image.repository (.Values.image.tag | default .Chart.AppVersion | toString) }}
+{{- with (.Values.global | default dict).imageRegistry }}
+{{- printf "%s/%s" (trimSuffix "/" .) $image }}
+{{- else }}
+{{- $image }}
+{{- end }}
+{{- end }}
+
+{{/*
+Image pull secrets of the pods: global.imagePullSecrets followed by
+imagePullSecrets
+*/}}
+{{- define "example.imagePullSecrets" -}}
+{{- $secrets := concat ((.Values.global | default dict).imagePullSecrets | default list) (.Values.imagePullSecrets | default list) | uniq }}
+{{- with $secrets }}
+{{- toYaml . }}
+{{- end }}
+{{- end }}
+
+{{/*
+Name of the headless service governing the statefulset
+*/}}
+{{- define "example.headlessServiceName" -}}
+{{- printf "%s-headless" (include "example.fullname" . | trunc 54 | trimSuffix "-") }}
+{{- end }}
+
+{{/*
+Name of the TLS secret issued by cert-manager for the ingress
+*/}}
+{{- define "example.certificateSecretName" -}}
+{{- default (printf "%s-tls" (include "example.fullname" .)) .Values.certificate.secretName }}
+{{- end }}
+
+{{/*
+Environment variables of the main container. .Values.env is either a list of
+EnvVar or a map of names to plain values or to a value source (secretKeyRef,
+configMapKeyRef, fieldRef, resourceFieldRef, optionally nested in valueFrom).
+The hosts of the enabled chart dependencies (.Values.dependencyEnv) are added
+*/}}
+{{- define "example.env" -}}
+{{- $env := list }}
+{{- if kindIs "map" .Values.env }}
+{{- range $name, $value := .Values.env }}
+{{- if kindIs "map" $value }}
+{{- $env = append $env (dict "name" $name "valueFrom" ($value.valueFrom | default $value)) }}
+{{- else }}
+{{- $env = append $env (dict "name" $name "value" ($value | toString)) }}
+{{- end }}
+{{- end }}
+{{- else if .Values.env }}
+{{- $env = concat $env .Values.env }}
+{{- end }}
+{{- range .Values.dependencyEnv }}
+{{- if include "example.dependencyEnabled" (list $ .condition) }}
+{{- $env = append $env (dict "name" .name "value" (.host | default (include "example.dependencyFullname" (list $ .dependency)))) }}
+{{- end }}
+{{- end }}
+{{- with $env }}
+{{- toYaml . }}
+{{- end }}
+{{- end }}
+
+{{/*
+Whether a chart dependency is enabled: (list $ "condition.path") returns true
+unless the condition path of the values is false, like Helm
+*/}}
+{{- define "example.dependencyEnabled" -}}
+{{- $value := (index . 0).Values }}
+{{- range splitList "." (index . 1) }}
+{{- if kindIs "map" $value }}
+{{- $value = get $value . }}
+{{- else }}
+{{- $value = "" }}
+{{- end }}
+{{- end }}
+{{- if or (not (kindIs "bool" $value)) $value }}true{{ end }}
+{{- end }}
+
+{{/*
+Fullname of a chart dependency, used as its service host: (list $ "name")
+follows the fullname convention of the charts (nameOverride, fullnameOverride)
+*/}}
+{{- define "example.dependencyFullname" -}}
+{{- $root := index . 0 }}
+{{- $name := index . 1 }}
+{{- $values := get $root.Values $name | default dict }}
+{{- if $values.fullnameOverride }}
+{{- $values.fullnameOverride | trunc 63 | trimSuffix "-" }}
+{{- else }}
+{{- $name = default $name $values.nameOverride }}
+{{- if contains $name $root.Release.Name }}
+{{- $root.Release.Name | trunc 63 | trimSuffix "-" }}
+{{- else }}
+{{- printf "%s-%s" $root.Release.Name $name | trunc 63 | trimSuffix "-" }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{/*
+Topology spread constraints of the pods, the labelSelector defaults to the
+selector labels of the chart
+*/}}
+{{- define "example.topologySpreadConstraints" -}}
+{{- $constraints := list }}
+{{- range .Values.topologySpreadConstraints }}
+{{- $constraint := deepCopy . }}
+{{- if not $constraint.labelSelector }}
+{{- $_ := set $constraint "labelSelector" (dict "matchLabels" (include "example.selectorLabels" $ | fromYaml)) }}
+{{- end }}
+{{- $constraints = append $constraints $constraint }}
+{{- end }}
+{{- toYaml $constraints }}
+{{- end }}
+
+{{/*
+Service port of the default (first) entry of .Values.ports
+*/}}
+{{- define "example.servicePort" -}}
+{{- with .Values.ports }}
+{{- $port := first . }}
+{{- $port.servicePort | default $port.containerPort }}
+{{- end }}
+{{- end }}
+
+{{/*
+Container port of the default (first) entry of .Values.ports
+*/}}
+{{- define "example.containerPort" -}}
+{{- with .Values.ports }}
+{{- (first .).containerPort }}
+{{- end }}
+{{- end }}
+
 {{/*
 Create the name of the service account to use
 */}}
@@ -0,0 +1,142 @@
+{{"{{"}}/*
+Pod template of the jobs of the CronJob and of the KEDA ScaledJob.
+*/{{"}}"}}
+{{"{{"}}- define "example.jobPodTemplate" -{{"}}"}}
+metadata:
+  labels:
+    {{"{{"}}- include "example.selectorLabels" . | nindent 4 {{"}}"}}
+spec:
+  restartPolicy: {{"{{"}} .Values.restartPolicy {{"}}"}}
+  hostname: {{"{{"}} include "example.fullname" . {{"}}"}}
+  {{"{{"}}- with include "example.imagePullSecrets" . {{"}}"}}
+  imagePullSecrets:
+    {{"{{"}}- . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{- if .ServiceAccount }}
+  serviceAccountName: {{"{{"}} include "example.serviceAccountName" . {{"}}"}}
+  {{- else }}
+  automountServiceAccountToken: false
+  {{- end }}
+  securityContext:
+    {{"{{"}}- toYaml .Values.podSecurityContext | nindent 4 {{"}}"}}
+  {{"{{"}}- with .Values.initContainers {{"}}"}}
+  initContainers:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  containers:
+    - name: {{"{{"}} .Chart.Name {{"}}"}}
+      securityContext:
+        {{"{{"}}- toYaml .Values.securityContext | nindent 8 {{"}}"}}
+      image: {{"{{"}} include "example.image" . | quote {{"}}"}}
+      {{"{{"}}- with .Values.command {{"}}"}}
+      command:
+        {{"{{"}}- toYaml . | nindent 8 {{"}}"}}
+      {{"{{"}}- end {{"}}"}}
+      {{"{{"}}- with .Values.args {{"}}"}}
+      args:
+        {{"{{"}}- toYaml . | nindent 8 {{"}}"}}
+      {{"{{"}}- end {{"}}"}}
+      {{- if .FilesConfigMap }}
+      volumeMounts:
+        - name: config-files
+          mountPath: {{"{{"}} .Values.configFiles.mountPath {{"}}"}}
+          readOnly: true
+        {{"{{"}}- with .Values.volumeMounts {{"}}"}}
+        {{"{{"}}- toYaml . | nindent 8 {{"}}"}}
+        {{"{{"}}- end {{"}}"}}
+      {{- else }}
+      {{"{{"}}- with .Values.volumeMounts {{"}}"}}
+      volumeMounts:
+        {{"{{"}}- toYaml . | nindent 8 {{"}}"}}
+      {{"{{"}}- end {{"}}"}}
+      {{- end }}
+      {{"{{"}}- with include "example.env" $ {{"}}"}}
+      env:
+        {{"{{"}}- . | nindent 8 {{"}}"}}
+      {{"{{"}}- end {{"}}"}}
+      {{- if .Configmap }}
+      envFrom:
+      - configMapRef:
+          name: {{"{{"}} include "example.fullname" . {{"}}"}}
+      {{"{{"}}- range .Values.additionalEnvFrom {{"}}"}}
+      - {{"{{"}}- . | toYaml | nindent 8 {{"}}"}}
+      {{"{{"}}- end {{"}}"}}
+      {{- end }}
+      imagePullPolicy: {{"{{"}} .Values.image.pullPolicy {{"}}"}}
+      {{- if or .ExposesPorts .Metrics }}
+      ports:
+        {{- if .ExposesPorts }}
+        {{"{{"}}- range .Values.ports {{"}}"}}
+        - name: {{"{{"}} .name {{"}}"}}
+          containerPort: {{"{{"}} .containerPort {{"}}"}}
+          protocol: {{"{{"}} .protocol | default "TCP" {{"}}"}}
+        {{"{{"}}- end {{"}}"}}
+        {{- end }}
+        {{- if .Metrics }}
+        - name: metrics
+          containerPort: {{"{{"}} .Values.metrics.port {{"}}"}}
+          protocol: TCP
+        {{- end }}
+      {{- end }}
+      resources:
+        {{"{{"}}- toYaml .Values.resources | nindent 8 {{"}}"}}
+    {{"{{"}}- with .Values.sidecars {{"}}"}}
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+    {{"{{"}}- end {{"}}"}}
+    {{"{{"}}- with .Values.extraContainers {{"}}"}}
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+    {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.nodeSelector {{"}}"}}
+  nodeSelector:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.affinity {{"}}"}}
+  affinity:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.tolerations {{"}}"}}
+  tolerations:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.topologySpreadConstraints {{"}}"}}
+  topologySpreadConstraints:
+    {{"{{"}}- include "example.topologySpreadConstraints" $ | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.priorityClassName {{"}}"}}
+  priorityClassName: {{"{{"}} . {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.runtimeClassName {{"}}"}}
+  runtimeClassName: {{"{{"}} . {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.schedulerName {{"}}"}}
+  schedulerName: {{"{{"}} . {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- if not (kindIs "invalid" .Values.terminationGracePeriodSeconds) {{"}}"}}
+  terminationGracePeriodSeconds: {{"{{"}} .Values.terminationGracePeriodSeconds {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.dnsPolicy {{"}}"}}
+  dnsPolicy: {{"{{"}} . {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.dnsConfig {{"}}"}}
+  dnsConfig:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{"{{"}}- with .Values.hostAliases {{"}}"}}
+  hostAliases:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{- if .FilesConfigMap }}
+  volumes:
+    - name: config-files
+      configMap:
+        name: {{"{{"}} include "example.fullname" . {{"}}"}}-files
+    {{"{{"}}- with .Values.volumes {{"}}"}}
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+    {{"{{"}}- end {{"}}"}}
+  {{- else }}
+  {{"{{"}}- with .Values.volumes {{"}}"}}
+  volumes:
+    {{"{{"}}- toYaml . | nindent 4 {{"}}"}}
+  {{"{{"}}- end {{"}}"}}
+  {{- end }}
+{{"{{"}}- end {{"}}"}}
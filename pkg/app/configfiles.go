@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// configFilesDir is the chart directory holding the configuration files served
+// by the files ConfigMap.
+const configFilesDir = "files"
+
+// configMapKeyRegexp validates ConfigMap keys, the copied files are flattened
+// so their base name becomes the key.
+var configMapKeyRegexp = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// copyConfigFiles copies the configuration files and the top-level files of the
+// configuration directory into the files/ directory of the chart.
+func (a *App) copyConfigFiles() error {
+	if !a.opts.FilesConfigMap() {
+		return nil
+	}
+
+	sources, err := a.configFileSources()
+	if err != nil {
+		return err
+	}
+
+	filesDir := a.pathManager.Join(a.chartPath, configFilesDir)
+	const dirPerm = 0755
+	if err := a.fs.MkdirAll(filesDir, dirPerm); err != nil {
+		return errors.NewFileSystemError("create-directory", "failed to create files directory", err).
+			WithChart(a.opts.ChartName).
+			WithFile(filesDir)
+	}
+
+	copied := make(map[string]string, len(sources))
+	for _, src := range sources {
+		name := filepath.Base(src)
+		if !configMapKeyRegexp.MatchString(name) {
+			return errors.NewValidationError("copy-config-file",
+				fmt.Sprintf("file name %q is not a valid ConfigMap key", name)).
+				WithChart(a.opts.ChartName).
+				WithFile(src)
+		}
+		if prev, exists := copied[name]; exists {
+			return errors.NewValidationError("copy-config-file",
+				fmt.Sprintf("file name %q is provided by both %s and %s", name, prev, src)).
+				WithChart(a.opts.ChartName).
+				WithFile(src)
+		}
+		copied[name] = src
+
+		content, err := a.fs.ReadFile(src)
+		if err != nil {
+			return errors.NewFileSystemError("copy-config-file", "failed to read configuration file", err).
+				WithChart(a.opts.ChartName).
+				WithFile(src)
+		}
+		dst := a.pathManager.Join(filesDir, name)
+		const filePerm = 0644
+		if err := a.fs.WriteFile(dst, content, filePerm); err != nil {
+			return errors.NewFileSystemError("copy-config-file", "failed to write configuration file", err).
+				WithChart(a.opts.ChartName).
+				WithFile(dst)
+		}
+	}
+	return nil
+}
+
+// configFileSources returns the files to copy: the explicit files followed by
+// the regular files found at the top level of the configuration directory.
+func (a *App) configFileSources() ([]string, error) {
+	sources := append([]string{}, a.opts.ConfigFiles...)
+	if a.opts.ConfigDir == "" {
+		return sources, nil
+	}
+
+	err := a.fs.Walk(a.opts.ConfigDir, func(p string, info os.FileInfo, erR error) error {
+		if erR != nil {
+			return erR
+		}
+		if info.IsDir() {
+			// ConfigMap keys are flat, nested directories are not copied
+			if p != a.opts.ConfigDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			sources = append(sources, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewFileSystemError("copy-config-file", "failed to walk configuration directory", err).
+			WithChart(a.opts.ChartName).
+			WithFile(a.opts.ConfigDir)
+	}
+	return sources, nil
+}
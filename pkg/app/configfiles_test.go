@@ -0,0 +1,105 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/mocks"
+)
+
+func TestApp_copyConfigFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          options
+		sourceFiles   map[string]string
+		expectedFiles map[string]string
+		errContains   string
+	}{
+		{
+			name:          "disabled",
+			opts:          options{ChartName: "test-chart"},
+			expectedFiles: map[string]string{},
+		},
+		{
+			name: "files and directory",
+			opts: options{
+				ChartName:   "test-chart",
+				ConfigFiles: []string{"/src/app.properties"},
+				ConfigDir:   "/conf",
+			},
+			sourceFiles: map[string]string{
+				"/src/app.properties": "key=example",
+				"/conf/nginx.conf":    "listen 80;",
+			},
+			expectedFiles: map[string]string{
+				"test-path/files/app.properties": "key=example",
+				"test-path/files/nginx.conf":     "listen 80;",
+			},
+		},
+		{
+			name: "invalid configmap key",
+			opts: options{
+				ChartName:   "test-chart",
+				ConfigFiles: []string{"/src/my config.yaml"},
+			},
+			sourceFiles: map[string]string{"/src/my config.yaml": "a: b"},
+			errContains: "is not a valid ConfigMap key",
+		},
+		{
+			name: "duplicate file name",
+			opts: options{
+				ChartName:   "test-chart",
+				ConfigFiles: []string{"/a/app.yaml"},
+				ConfigDir:   "/b",
+			},
+			sourceFiles: map[string]string{
+				"/a/app.yaml": "a: b",
+				"/b/app.yaml": "c: d",
+			},
+			errContains: "is provided by both /a/app.yaml and /b/app.yaml",
+		},
+		{
+			name: "missing file",
+			opts: options{
+				ChartName:   "test-chart",
+				ConfigFiles: []string{"/src/missing.yaml"},
+			},
+			errContains: "failed to read configuration file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFS := mocks.NewMockFileSystem()
+			for name, content := range tt.sourceFiles {
+				mockFS.Files[name] = []byte(content)
+			}
+
+			app := &App{
+				chartPath:         "test-path",
+				opts:              tt.opts,
+				fs:                mockFS,
+				templateProcessor: mocks.NewMockTemplateProcessor(),
+				pathManager:       mocks.NewMockPathManager(),
+				chartTemplateFS:   GetChartTemplate(),
+			}
+
+			err := app.copyConfigFiles()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("copyConfigFiles() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("copyConfigFiles() error = %v", err)
+			}
+
+			for name, want := range tt.expectedFiles {
+				if got := string(mockFS.Files[name]); got != want {
+					t.Errorf("file %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// valuesKeyRegexp matches a top-level key of the values.yaml template.
+var valuesKeyRegexp = regexp.MustCompile(`(?m)^([a-zA-Z][a-zA-Z0-9]*):`)
+
+// Dependency is an external chart declared in the dependencies of Chart.yaml.
+type Dependency struct {
+	Name       string
+	Repository string
+	Version    string
+	// Condition is the values path enabling the dependency, <name>.enabled by
+	// default, empty for a library chart.
+	Condition string
+}
+
+// EnvName returns the environment variable of the main container holding the
+// service host of the dependency.
+func (d Dependency) EnvName() string {
+	return strings.ToUpper(strings.ReplaceAll(d.Name, "-", "_")) + "_HOST"
+}
+
+// ValuesKeys returns the top-level keys the generator may write in
+// values.yaml, and global which Helm shares with the dependencies. The values
+// block and the condition of a dependency must not use them.
+func ValuesKeys() []string {
+	content, err := chartTemplate.ReadFile("chartTemplate/values.yaml")
+	if err != nil {
+		return []string{"global"}
+	}
+	keys := []string{"global"}
+	for _, m := range valuesKeyRegexp.FindAllStringSubmatch(string(content), -1) {
+		keys = append(keys, m[1])
+	}
+	return keys
+}
+
+// ChartDependencies returns the dependencies of Chart.yaml: the library chart
+// used by the application, if any, followed by the dependencies.
+func (o options) ChartDependencies() []Dependency {
+	if !o.UsesLibrary() {
+		return o.Dependencies
+	}
+	return append([]Dependency{o.Library}, o.Dependencies...)
+}
+
+// userValues returns the values given by the user that are written verbatim
+// in the chart and must not be affected by the "example" placeholder.
+func (o options) userValues() []string {
+	var values []string
+	for _, d := range o.ChartDependencies() {
+		values = append(values, d.Repository)
+	}
+	for _, s := range o.Sidecars {
+		values = append(values, s.Image)
+	}
+	values = append(values, o.Notes...)
+	if o.Dockerfile != nil {
+		if o.Dockerfile.Healthcheck != nil {
+			values = append(values, o.Dockerfile.Healthcheck.Command...)
+		}
+		for _, e := range o.Dockerfile.Env {
+			values = append(values, e.Value)
+		}
+		for _, v := range o.Dockerfile.Volumes {
+			values = append(values, v.MountPath)
+		}
+	}
+	return append(values, o.userHosts...)
+}
+
+// replacePlaceholder replaces the "example" placeholder with the chart name,
+// leaving the values given by the user untouched.
+func (o options) replacePlaceholder(content string) string {
+	values := o.userValues()
+	// longest first, a value may contain another one
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	var protected []string
+	for i, v := range values {
+		if strings.Contains(v, "example") && strings.Contains(content, v) {
+			marker := fmt.Sprintf("\x00%d\x00", i)
+			content = strings.ReplaceAll(content, v, marker)
+			protected = append(protected, marker, v)
+		}
+	}
+	content = strings.ReplaceAll(content, "example", o.ChartName)
+	return strings.NewReplacer(protected...).Replace(content)
+}
+
+// valuesNode is a key of the values generated for the dependencies.
+type valuesNode struct {
+	key      string
+	comment  string
+	leaf     bool
+	children []*valuesNode
+}
+
+// child returns the child node with the given key, creating it when needed.
+func (n *valuesNode) child(key string) *valuesNode {
+	for _, c := range n.children {
+		if c.key == key {
+			return c
+		}
+	}
+	c := &valuesNode{key: key}
+	n.children = append(n.children, c)
+	return c
+}
+
+// write writes the node and its children at the given indentation.
+func (n *valuesNode) write(b *strings.Builder, indent string) {
+	if n.comment != "" {
+		b.WriteString(indent + "# -- " + n.comment + "\n")
+	}
+	switch {
+	case n.leaf:
+		b.WriteString(indent + n.key + ": true\n")
+	case len(n.children) == 0:
+		b.WriteString(indent + n.key + ": {}\n")
+	default:
+		b.WriteString(indent + n.key + ":\n")
+		for _, c := range n.children {
+			c.write(b, indent+"  ")
+		}
+	}
+}
+
+// DependencyValues returns the values of the dependencies: one block per
+// dependency, holding the values passed to its chart, and the conditions
+// enabling them.
+func (o options) DependencyValues() string {
+	root := &valuesNode{}
+	for _, d := range o.Dependencies {
+		root.child(d.Name).comment = "values of the " + d.Name + " chart (" + d.Repository + ")"
+	}
+	for _, d := range o.Dependencies {
+		node := root
+		for _, key := range strings.Split(d.Condition, ".") {
+			node = node.child(key)
+		}
+		node.leaf = true
+		node.comment = "deploy the " + d.Name + " dependency"
+	}
+
+	var b strings.Builder
+	for _, c := range root.children {
+		b.WriteString("\n")
+		c.write(&b, "")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
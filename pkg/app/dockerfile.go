@@ -0,0 +1,454 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// dockerfile holds the settings of the image read from the instructions of
+// the last stage of its Dockerfile.
+type dockerfile struct {
+	// Ports are the EXPOSE ports, the first TCP port is the default http
+	// port.
+	Ports []Port
+	// Healthcheck is the HEALTHCHECK of the image, nil without one.
+	Healthcheck *healthcheck
+	// User is the numeric USER of the image, nil for root or a user name.
+	User *imageUser
+	// Volumes are the VOLUME mount points.
+	Volumes []imageVolume
+	// Env are the ENV variables, the values referencing unknown variables
+	// are left out.
+	Env []envVar
+}
+
+// healthcheck is the HEALTHCHECK of an image converted into an exec probe.
+type healthcheck struct {
+	Command             []string
+	PeriodSeconds       int
+	TimeoutSeconds      int
+	FailureThreshold    int
+	InitialDelaySeconds int
+}
+
+// imageUser is the numeric user of an image.
+type imageUser struct {
+	UID      int
+	GID      int
+	HasGroup bool
+}
+
+// imageVolume is a VOLUME of an image, mounted from an emptyDir.
+type imageVolume struct {
+	Name      string
+	MountPath string
+}
+
+// envVar is an ENV variable of an image.
+type envVar struct {
+	Name  string
+	Value string
+}
+
+// dockerfileState is the state of a stage while the Dockerfile is parsed.
+type dockerfileState struct {
+	dockerfile
+	vars map[string]string
+}
+
+// maxPort is the highest valid port.
+const maxPort = 65535
+
+// dockerVariable matches $VAR, ${VAR} and ${VAR:-default} (or :+).
+var dockerVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([-+])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// nonVolumeName matches the characters of a mount path replaced in the name
+// of its volume.
+var nonVolumeName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// parseDockerfile parses the instructions of a Dockerfile. The stages built
+// FROM a previous stage inherit its settings, the settings of the base images
+// are unknown.
+func parseDockerfile(content []byte) (*dockerfile, error) {
+	stages := map[string]*dockerfileState{}
+	state := &dockerfileState{vars: map[string]string{}}
+	// the ARGs declared before the first FROM
+	global := state.vars
+	for _, inst := range dockerInstructions(string(content)) {
+		keyword, args, _ := strings.Cut(inst.text, " ")
+		args = strings.TrimSpace(args)
+		var err error
+		switch strings.ToUpper(keyword) {
+		case "FROM":
+			state = newStage(stages, args)
+		case "ARG":
+			for _, arg := range shellWords(args) {
+				name, value, hasDefault := strings.Cut(arg, "=")
+				if _, ok := state.vars[name]; ok && !hasDefault {
+					continue
+				}
+				if !hasDefault {
+					// the value is the default of the global ARG, or is unknown
+					// until given with --build-arg
+					value, hasDefault = global[name]
+				}
+				if hasDefault {
+					state.vars[name] = value
+				}
+			}
+		case "ENV":
+			state.parseEnv(args)
+		case "EXPOSE":
+			err = state.parseExpose(args)
+		case "HEALTHCHECK":
+			err = state.parseHealthcheck(args)
+		case "USER":
+			state.parseUser(args)
+		case "VOLUME":
+			err = state.parseVolume(args)
+		}
+		if err != nil {
+			return nil, errors.NewValidationError("parse-dockerfile", err.Error()).
+				WithContext("line", strconv.Itoa(inst.line))
+		}
+	}
+	return &state.dockerfile, nil
+}
+
+// instruction is an instruction of a Dockerfile, its continuation lines
+// joined.
+type instruction struct {
+	line int
+	text string
+}
+
+// dockerInstructions splits a Dockerfile into instructions, the comments and
+// the parser directives are left out.
+func dockerInstructions(content string) []instruction {
+	var instructions []instruction
+	var current strings.Builder
+	start := 0
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") || (line == "" && current.Len() == 0) {
+			continue
+		}
+		if current.Len() == 0 {
+			start = n + 1
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\") + " ")
+			continue
+		}
+		current.WriteString(line)
+		instructions = append(instructions, instruction{line: start, text: strings.TrimSpace(current.String())})
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		instructions = append(instructions, instruction{line: start, text: strings.TrimSpace(current.String())})
+	}
+	return instructions
+}
+
+// newStage starts the stage of a FROM instruction, from the state of a
+// previous stage when it is built from it.
+func newStage(stages map[string]*dockerfileState, args string) *dockerfileState {
+	words := strings.Fields(args)
+	var image string
+	for _, word := range words {
+		if !strings.HasPrefix(word, "--") {
+			image = word
+			break
+		}
+	}
+	state := &dockerfileState{vars: map[string]string{}}
+	if base, ok := stages[strings.ToLower(image)]; ok {
+		state.dockerfile = base.dockerfile
+		state.Ports = append([]Port(nil), base.Ports...)
+		state.Volumes = append([]imageVolume(nil), base.Volumes...)
+		state.Env = append([]envVar(nil), base.Env...)
+		for k, v := range base.vars {
+			state.vars[k] = v
+		}
+	}
+	if len(words) >= 3 && strings.EqualFold(words[len(words)-2], "AS") {
+		stages[strings.ToLower(words[len(words)-1])] = state
+	}
+	return state
+}
+
+// expand replaces the variables of a value, ok is false when a variable is
+// unknown.
+func (s *dockerfileState) expand(value string) (string, bool) {
+	ok := true
+	expanded := dockerVariable.ReplaceAllStringFunc(value, func(match string) string {
+		groups := dockerVariable.FindStringSubmatch(match)
+		name, operator, operand := groups[1], groups[2], groups[3]
+		if name == "" {
+			name = groups[4]
+		}
+		v, set := s.vars[name]
+		switch {
+		case operator == "-" && v == "":
+			return operand
+		case operator == "+" && v != "":
+			return operand
+		case operator == "+":
+			return ""
+		case !set:
+			ok = false
+		}
+		return v
+	})
+	return expanded, ok
+}
+
+// parseEnv parses ENV name=value ... or the legacy ENV name value.
+func (s *dockerfileState) parseEnv(args string) {
+	words := shellWords(args)
+	if len(words) > 0 && !strings.Contains(words[0], "=") {
+		name, value, _ := strings.Cut(args, " ")
+		words = []string{name + "=" + strings.TrimSpace(value)}
+	}
+	for _, word := range words {
+		name, value, _ := strings.Cut(word, "=")
+		value, ok := s.expand(value)
+		s.vars[name] = value
+		s.Env = removeEnv(s.Env, name)
+		if ok {
+			s.Env = append(s.Env, envVar{Name: name, Value: value})
+		}
+	}
+}
+
+func removeEnv(env []envVar, name string) []envVar {
+	for i, e := range env {
+		if e.Name == name {
+			return append(env[:i:i], env[i+1:]...)
+		}
+	}
+	return env
+}
+
+// parseExpose parses EXPOSE port[/protocol] ...
+func (s *dockerfileState) parseExpose(args string) error {
+	for _, word := range strings.Fields(args) {
+		word, _ = s.expand(word)
+		number, protocol, _ := strings.Cut(word, "/")
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > maxPort {
+			return fmt.Errorf("invalid EXPOSE port %q", word)
+		}
+		protocol = strings.ToUpper(protocol)
+		if protocol == "TCP" {
+			protocol = ""
+		}
+		if protocol != "" && protocol != "UDP" && protocol != "SCTP" {
+			return fmt.Errorf("invalid EXPOSE protocol %q", word)
+		}
+		port := Port{Number: n, Protocol: protocol}
+		exposed := false
+		for _, p := range s.Ports {
+			exposed = exposed || (p.Number == n && p.Protocol == protocol)
+		}
+		if exposed {
+			continue
+		}
+		if protocol == "" && !hasTCPPort(s.Ports) {
+			// the default port of the probes
+			port.Name = "http"
+			s.Ports = append([]Port{port}, s.Ports...)
+			continue
+		}
+		port.Name = fmt.Sprintf("%s-%d", strings.ToLower(valueOr(protocol, "tcp")), n)
+		s.Ports = append(s.Ports, port)
+	}
+	return nil
+}
+
+func hasTCPPort(ports []Port) bool {
+	for _, p := range ports {
+		if p.Protocol == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHealthcheck parses HEALTHCHECK [options] CMD command or HEALTHCHECK
+// NONE, the durations and the retries default to the ones of docker.
+func (s *dockerfileState) parseHealthcheck(args string) error {
+	if strings.EqualFold(strings.TrimSpace(args), "NONE") {
+		s.Healthcheck = nil
+		return nil
+	}
+	h := &healthcheck{PeriodSeconds: 30, TimeoutSeconds: 30, FailureThreshold: 3}
+	for strings.HasPrefix(args, "--") {
+		var option string
+		option, args, _ = strings.Cut(args, " ")
+		args = strings.TrimSpace(args)
+		name, value, _ := strings.Cut(strings.TrimPrefix(option, "--"), "=")
+		var err error
+		switch name {
+		case "interval":
+			h.PeriodSeconds, err = durationSeconds(value)
+		case "timeout":
+			h.TimeoutSeconds, err = durationSeconds(value)
+		case "start-period":
+			h.InitialDelaySeconds, err = durationSeconds(value)
+		case "retries":
+			h.FailureThreshold, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid HEALTHCHECK option %s", option)
+		}
+	}
+	keyword, command, _ := strings.Cut(args, " ")
+	if !strings.EqualFold(keyword, "CMD") {
+		return fmt.Errorf("HEALTHCHECK expects CMD or NONE")
+	}
+	var err error
+	if h.Command, err = commandArgs(strings.TrimSpace(command)); err != nil {
+		return fmt.Errorf("invalid HEALTHCHECK command: %w", err)
+	}
+	s.Healthcheck = h
+	return nil
+}
+
+// durationSeconds converts a duration (1m30s) into seconds, at least 1.
+func durationSeconds(value string) (int, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return max(1, int((d+time.Second-1)/time.Second)), nil
+}
+
+// commandArgs returns the arguments of a command in exec form (a JSON array)
+// or in shell form, run by /bin/sh -c.
+func commandArgs(command string) ([]string, error) {
+	if strings.HasPrefix(command, "[") {
+		var args []string
+		if err := json.Unmarshal([]byte(command), &args); err == nil {
+			return args, nil
+		}
+	}
+	if command == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+	return []string{"/bin/sh", "-c", command}, nil
+}
+
+// parseUser parses USER user[:group], the user is kept when it is numeric
+// and not root.
+func (s *dockerfileState) parseUser(args string) {
+	args, _ = s.expand(args)
+	user, group, hasGroup := strings.Cut(args, ":")
+	uid, err := strconv.Atoi(user)
+	if err != nil || uid == 0 {
+		s.User = nil
+		return
+	}
+	s.User = &imageUser{UID: uid}
+	if gid, err := strconv.Atoi(group); hasGroup && err == nil {
+		s.User.GID, s.User.HasGroup = gid, true
+	}
+}
+
+// parseVolume parses VOLUME ["/path", ...] or VOLUME /path ...
+func (s *dockerfileState) parseVolume(args string) error {
+	paths := strings.Fields(args)
+	if strings.HasPrefix(args, "[") {
+		if err := json.Unmarshal([]byte(args), &paths); err != nil {
+			return fmt.Errorf("invalid VOLUME %s", args)
+		}
+	}
+	for _, path := range paths {
+		path, _ = s.expand(path)
+		name := strings.Trim(nonVolumeName.ReplaceAllString(strings.ToLower(path), "-"), "-")
+		if name == "" {
+			return fmt.Errorf("invalid VOLUME %q", path)
+		}
+		s.Volumes = append(s.Volumes, imageVolume{Name: name, MountPath: path})
+	}
+	return nil
+}
+
+// shellWords splits the arguments of an instruction into words, the quotes
+// grouping the words are removed.
+func shellWords(args string) []string {
+	var words []string
+	var current strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+	for _, r := range args {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+func valueOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// readDockerfile reads the Dockerfile of the image, its ports and its
+// healthcheck replace the default ports and probes.
+func (a *App) readDockerfile() error {
+	if a.dockerfilePath == "" {
+		return nil
+	}
+	content, err := a.fs.ReadFile(a.dockerfilePath)
+	if err != nil {
+		return errors.NewFileSystemError("read-dockerfile", "failed to read Dockerfile", err).
+			WithChart(a.opts.ChartName).
+			WithFile(a.dockerfilePath)
+	}
+	d, err := parseDockerfile(content)
+	if err != nil {
+		if chartErr, ok := err.(*errors.ChartError); ok {
+			return chartErr.WithChart(a.opts.ChartName).WithFile(a.dockerfilePath)
+		}
+		return err
+	}
+	a.opts.Dockerfile = d
+	if len(a.opts.Ports) == 0 {
+		a.opts.Ports = d.Ports
+	}
+	if d.Healthcheck != nil && a.opts.ProbeType == ProbeHTTP && a.opts.ProbePath == "/" {
+		a.opts.ProbeType = ProbeExec
+	}
+	return nil
+}
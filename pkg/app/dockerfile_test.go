@@ -0,0 +1,192 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/mocks"
+)
+
+func TestParseDockerfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    *dockerfile
+		errContains string
+	}{
+		{
+			name: "last stage inheriting a previous stage",
+			content: `# syntax=docker/dockerfile:1
+FROM golang:1.23 AS build
+EXPOSE 9999
+ENV CGO_ENABLED=0
+
+FROM alpine:3.20 AS base
+ARG PORT=8080
+ENV HOME_DIR=/srv/app \
+    LISTEN=":${PORT}" \
+    PATH=/srv/app/bin:$PATH
+ENV GREETING hello world
+expose 53/udp ${PORT} 9090/tcp 8080
+VOLUME ["/data", "/var/cache/app"]
+USER 10001:10001
+
+FROM base
+HEALTHCHECK --interval=15s --timeout=3s --start-period=1m30s --retries=4 \
+  CMD wget -qO- http://localhost:8080/healthz || exit 1
+`,
+			expected: &dockerfile{
+				Ports: []Port{
+					{Name: "http", Number: 8080},
+					{Name: "udp-53", Number: 53, Protocol: "UDP"},
+					{Name: "tcp-9090", Number: 9090},
+				},
+				Healthcheck: &healthcheck{
+					Command:             []string{"/bin/sh", "-c", "wget -qO- http://localhost:8080/healthz || exit 1"},
+					PeriodSeconds:       15,
+					TimeoutSeconds:      3,
+					FailureThreshold:    4,
+					InitialDelaySeconds: 90,
+				},
+				User: &imageUser{UID: 10001, GID: 10001, HasGroup: true},
+				Volumes: []imageVolume{
+					{Name: "data", MountPath: "/data"},
+					{Name: "var-cache-app", MountPath: "/var/cache/app"},
+				},
+				Env: []envVar{
+					{Name: "HOME_DIR", Value: "/srv/app"},
+					{Name: "LISTEN", Value: ":8080"},
+					{Name: "GREETING", Value: "hello world"},
+				},
+			},
+		},
+		{
+			name: "exec healthcheck with the defaults of docker",
+			content: `FROM nginx
+HEALTHCHECK CMD ["curl", "-f", "http://localhost/"]
+USER nginx
+VOLUME /cache /logs
+`,
+			expected: &dockerfile{
+				Healthcheck: &healthcheck{
+					Command:          []string{"curl", "-f", "http://localhost/"},
+					PeriodSeconds:    30,
+					TimeoutSeconds:   30,
+					FailureThreshold: 3,
+				},
+				Volumes: []imageVolume{{Name: "cache", MountPath: "/cache"}, {Name: "logs", MountPath: "/logs"}},
+			},
+		},
+		{
+			name: "healthcheck disabled and root user",
+			content: `FROM app AS base
+HEALTHCHECK CMD true
+USER 1000
+FROM base
+HEALTHCHECK NONE
+USER 0
+`,
+			expected: &dockerfile{},
+		},
+		{
+			name: "arguments without default",
+			content: `ARG REGISTRY=docker.io
+ARG VERSION
+FROM ${REGISTRY}/alpine
+ARG REGISTRY
+ARG VERSION
+ARG RELEASE=
+ENV APP_VERSION=$VERSION \
+    APP_REGISTRY=$REGISTRY \
+    APP_RELEASE=$RELEASE
+`,
+			expected: &dockerfile{
+				Env: []envVar{
+					{Name: "APP_REGISTRY", Value: "docker.io"},
+					{Name: "APP_RELEASE", Value: ""},
+				},
+			},
+		},
+		{
+			name:        "invalid port",
+			content:     "FROM nginx\nEXPOSE http\n",
+			errContains: `invalid EXPOSE port "http"`,
+		},
+		{
+			name:        "invalid healthcheck",
+			content:     "FROM nginx\n\nHEALTHCHECK --interval=often CMD true\n",
+			errContains: "invalid HEALTHCHECK option --interval=often; context: line=3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDockerfile([]byte(tt.content))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("parseDockerfile() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDockerfile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseDockerfile() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApp_readDockerfile(t *testing.T) {
+	const content = "FROM alpine\nEXPOSE 3000\nHEALTHCHECK CMD true\n"
+	tests := []struct {
+		name          string
+		opts          options
+		expectedPorts []Port
+		expectedProbe string
+	}{
+		{
+			name:          "defaults replaced",
+			opts:          options{ChartName: "test-chart", ProbeType: ProbeHTTP, ProbePath: "/"},
+			expectedPorts: []Port{{Name: "http", Number: 3000}},
+			expectedProbe: ProbeExec,
+		},
+		{
+			name:          "options given on the command line",
+			opts:          options{ChartName: "test-chart", ProbeType: ProbeTCP, ProbePath: "/", Ports: []Port{{Name: "web", Number: 80}}},
+			expectedPorts: []Port{{Name: "web", Number: 80}},
+			expectedProbe: ProbeTCP,
+		},
+		{
+			name:          "custom http probe path",
+			opts:          options{ChartName: "test-chart", ProbeType: ProbeHTTP, ProbePath: "/healthz"},
+			expectedPorts: []Port{{Name: "http", Number: 3000}},
+			expectedProbe: ProbeHTTP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFS := mocks.NewMockFileSystem()
+			mockFS.Files["/src/Dockerfile"] = []byte(content)
+			app := &App{opts: tt.opts, fs: mockFS, dockerfilePath: "/src/Dockerfile"}
+
+			if err := app.readDockerfile(); err != nil {
+				t.Fatalf("readDockerfile() error = %v", err)
+			}
+			if !reflect.DeepEqual(app.opts.Ports, tt.expectedPorts) {
+				t.Errorf("ports = %v, want %v", app.opts.Ports, tt.expectedPorts)
+			}
+			if app.opts.ProbeType != tt.expectedProbe {
+				t.Errorf("probe type = %q, want %q", app.opts.ProbeType, tt.expectedProbe)
+			}
+		})
+	}
+
+	app := &App{opts: options{ChartName: "test-chart"}, fs: mocks.NewMockFileSystem(), dockerfilePath: "/src/Dockerfile"}
+	if err := app.readDockerfile(); err == nil || !strings.Contains(err.Error(), "failed to read Dockerfile") {
+		t.Errorf("readDockerfile() error = %v, want error containing %q", err, "failed to read Dockerfile")
+	}
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// Environment is the profile of a deployment environment, written in the
+// values-<name>.yaml overlay of the chart.
+type Environment struct {
+	Name         string         `yaml:"-"`
+	ReplicaCount int            `yaml:"replicaCount"`
+	Resources    EnvResources   `yaml:"resources"`
+	Autoscaling  EnvAutoscaling `yaml:"autoscaling"`
+	// IngressHost is the host of the ingress and of the HTTPRoute, {chart}
+	// and {env} are replaced with the chart and environment names.
+	IngressHost string `yaml:"ingressHost"`
+}
+
+// EnvResources are the resources of the main container in an environment.
+type EnvResources struct {
+	Requests ResourceList `yaml:"requests"`
+	Limits   ResourceList `yaml:"limits"`
+}
+
+// ResourceList is an amount of CPU and memory.
+type ResourceList struct {
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+// IsZero reports whether no resources are set.
+func (r EnvResources) IsZero() bool {
+	return r.Requests.IsZero() && r.Limits.IsZero()
+}
+
+// IsZero reports whether neither CPU nor memory is set.
+func (r ResourceList) IsZero() bool {
+	return r.CPU == "" && r.Memory == ""
+}
+
+// EnvAutoscaling is the HPA configuration of an environment.
+type EnvAutoscaling struct {
+	Enabled     bool `yaml:"enabled"`
+	MinReplicas int  `yaml:"minReplicas"`
+	MaxReplicas int  `yaml:"maxReplicas"`
+}
+
+// DefaultEnvironments are the built-in environment profiles.
+var DefaultEnvironments = map[string]Environment{
+	"dev": {
+		ReplicaCount: 1,
+		Resources: EnvResources{
+			Requests: ResourceList{CPU: "50m", Memory: "64Mi"},
+			Limits:   ResourceList{CPU: "200m", Memory: "128Mi"},
+		},
+		Autoscaling: EnvAutoscaling{Enabled: false, MinReplicas: 1, MaxReplicas: 2},
+		IngressHost: "{chart}.dev.local",
+	},
+	"staging": {
+		ReplicaCount: 2,
+		Resources: EnvResources{
+			Requests: ResourceList{CPU: "100m", Memory: "128Mi"},
+			Limits:   ResourceList{CPU: "500m", Memory: "256Mi"},
+		},
+		Autoscaling: EnvAutoscaling{Enabled: true, MinReplicas: 2, MaxReplicas: 4},
+		IngressHost: "{chart}.staging.local",
+	},
+	"prod": {
+		ReplicaCount: 3,
+		Resources: EnvResources{
+			Requests: ResourceList{CPU: "250m", Memory: "256Mi"},
+			Limits:   ResourceList{CPU: "1", Memory: "512Mi"},
+		},
+		Autoscaling: EnvAutoscaling{Enabled: true, MinReplicas: 3, MaxReplicas: 10},
+		IngressHost: "{chart}.local",
+	},
+}
+
+// environmentValues is the data of the values-<env>.yaml template.
+type environmentValues struct {
+	options
+	Env Environment
+}
+
+// Host returns the ingress host of the environment for the chart.
+func (e Environment) Host(chartName string) string {
+	return strings.NewReplacer("{chart}", chartName, "{env}", e.Name).Replace(e.IngressHost)
+}
+
+// resolveEnvironments returns the profiles of the requested environments: the
+// built-in profile of the same name overridden by the entry of the profile
+// file, if any.
+func (a *App) resolveEnvironments() ([]Environment, error) {
+	profiles := map[string]yaml.Node{}
+	if a.envProfile != "" {
+		content, err := a.fs.ReadFile(a.envProfile)
+		if err != nil {
+			return nil, errors.NewFileSystemError("read-env-profile", "failed to read environment profile", err).
+				WithChart(a.opts.ChartName).
+				WithFile(a.envProfile)
+		}
+		if err := yaml.Unmarshal(content, &profiles); err != nil {
+			return nil, errors.NewValidationError("read-env-profile", "invalid environment profile: "+err.Error()).
+				WithChart(a.opts.ChartName).
+				WithFile(a.envProfile)
+		}
+	}
+
+	environments := make([]Environment, 0, len(a.environments))
+	for _, name := range a.environments {
+		env, builtIn := DefaultEnvironments[name]
+		node, inProfile := profiles[name]
+		if !builtIn && !inProfile {
+			return nil, errors.NewValidationError("resolve-environment",
+				fmt.Sprintf("no profile for environment %q, define it in the environment profile", name)).
+				WithChart(a.opts.ChartName)
+		}
+		if inProfile {
+			if err := node.Decode(&env); err != nil {
+				return nil, errors.NewValidationError("read-env-profile",
+					fmt.Sprintf("invalid profile of environment %q: %v", name, err)).
+					WithChart(a.opts.ChartName).
+					WithFile(a.envProfile)
+			}
+		}
+		if as := env.Autoscaling; as.Enabled && (as.MinReplicas < 1 || as.MaxReplicas < as.MinReplicas) {
+			return nil, errors.NewValidationError("resolve-environment",
+				fmt.Sprintf("autoscaling of environment %q needs 1 <= minReplicas <= maxReplicas", name)).
+				WithChart(a.opts.ChartName)
+		}
+		env.Name = name
+		environments = append(environments, env)
+		a.opts.userHosts = append(a.opts.userHosts, env.Host(a.opts.ChartName))
+	}
+	return environments, nil
+}
+
+// generateEnvironmentFiles writes the values-<env>.yaml overlays.
+func (a *App) generateEnvironmentFiles() error {
+	if len(a.environments) == 0 || a.opts.IsLibrary() {
+		return nil
+	}
+	environments, err := a.resolveEnvironments()
+	if err != nil {
+		return err
+	}
+	for _, env := range environments {
+		outputPath := a.pathManager.Join(a.chartPath, "values-"+env.Name+".yaml")
+		data := environmentValues{options: a.opts, Env: env}
+		if err := a.createFileFromTemplateData("chartTemplate/values-env.yaml", outputPath, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
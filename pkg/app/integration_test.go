@@ -18,12 +18,22 @@ func TestGenerateChart_Integration(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	tests := []struct {
-		name           string
-		chartName      string
-		options        map[string]bool
-		expectedFiles  []string
-		expectedDirs   []string
-		fileChecks     map[string]func(string) error
+		name            string
+		chartName       string
+		options         map[string]bool
+		sidecars        []Sidecar
+		probeType       string
+		ports           []Port
+		securityProfile string
+		dependencies    []Dependency
+		chartType       string
+		library         Dependency
+		environments    []string
+		envProfile      string
+		dockerfile      string
+		expectedFiles   []string
+		expectedDirs    []string
+		fileChecks      map[string]func(string) error
 	}{
 		{
 			name:      "basic chart generation",
@@ -84,6 +94,254 @@ func TestGenerateChart_Integration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "chart with metrics and service",
+			chartName: "metrics-app",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+				"metrics":    true,
+			},
+			expectedFiles: []string{
+				"templates/deployment.yaml",
+				"templates/service.yaml",
+				"templates/servicemonitor.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/deployment.yaml": func(content string) error {
+					if !strings.Contains(content, "name: metrics") {
+						return &ValidationError{Field: "deployment.yaml", Message: "metrics port not found in deployment template"}
+					}
+					return nil
+				},
+				"templates/servicemonitor.yaml": func(content string) error {
+					if !strings.Contains(content, `.Capabilities.APIVersions.Has "monitoring.coreos.com/v1"`) {
+						return &ValidationError{Field: "servicemonitor.yaml", Message: "capabilities guard not found"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with metrics without service",
+			chartName: "metrics-ds",
+			options: map[string]bool{
+				"daemonset": true,
+				"metrics":   true,
+			},
+			expectedFiles: []string{
+				"templates/daemonset.yaml",
+				"templates/podmonitor.yaml",
+			},
+		},
+		{
+			name:      "cronjob chart with metrics",
+			chartName: "metrics-cj",
+			options: map[string]bool{
+				"cronjob": true,
+				"metrics": true,
+			},
+			expectedFiles: []string{
+				"templates/cronjob.yaml",
+				"templates/podmonitor.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/_job.tpl": func(content string) error {
+					if !strings.Contains(content, "- name: metrics\n          containerPort: {{ .Values.metrics.port }}") {
+						return &ValidationError{Field: "_job.tpl", Message: "metrics port not declared by the job container"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with httproute",
+			chartName: "route-app",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+				"httproute":  true,
+			},
+			expectedFiles: []string{
+				"templates/service.yaml",
+				"templates/httproute.yaml",
+				"templates/NOTES.txt",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/httproute.yaml": func(content string) error {
+					if !strings.Contains(content, "gateway.networking.k8s.io/v1") {
+						return &ValidationError{Field: "httproute.yaml", Message: "gateway api version not found"}
+					}
+					return nil
+				},
+				"templates/NOTES.txt": func(content string) error {
+					if !strings.Contains(content, ".Values.httpRoute.hostnames") {
+						return &ValidationError{Field: "NOTES.txt", Message: "httproute notes not found"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with ingress and httproute",
+			chartName: "route-ing",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+				"ingress":    true,
+				"httproute":  true,
+			},
+			expectedFiles: []string{
+				"templates/ingress.yaml",
+				"templates/httproute.yaml",
+				"templates/NOTES.txt",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/NOTES.txt": func(content string) error {
+					if strings.Count(content, "Get the application URL") != 1 {
+						return &ValidationError{Field: "NOTES.txt", Message: "application URL header not printed once"}
+					}
+					if !strings.Contains(content, ".Values.ingress.hosts") || !strings.Contains(content, ".Values.httpRoute.hostnames") {
+						return &ValidationError{Field: "NOTES.txt", Message: "ingress and httproute URLs not listed"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "statefulset chart with volumes",
+			chartName: "sts-app",
+			options: map[string]bool{
+				"statefulset": true,
+				"service":     true,
+				"volumes":     true,
+			},
+			expectedFiles: []string{
+				"templates/statefulset.yaml",
+				"templates/service.yaml",
+				"templates/service-headless.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/statefulset.yaml": func(content string) error {
+					if !strings.Contains(content, `serviceName: {{ include "sts-app.headlessServiceName" . }}`) {
+						return &ValidationError{Field: "statefulset.yaml", Message: "serviceName not set to the headless service"}
+					}
+					if !strings.Contains(content, "volumeClaimTemplates:") || !strings.Contains(content, ".Values.persistence.size") {
+						return &ValidationError{Field: "statefulset.yaml", Message: "volumeClaimTemplates not driven by persistence values"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "statefulset chart with hpa",
+			chartName: "sts-hpa",
+			options: map[string]bool{
+				"statefulset": true,
+				"hpa":         true,
+			},
+			expectedFiles: []string{
+				"templates/statefulset.yaml",
+				"templates/hpa.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/hpa.yaml": func(content string) error {
+					if !strings.Contains(content, "kind: StatefulSet") {
+						return &ValidationError{Field: "hpa.yaml", Message: "hpa does not target the statefulset"}
+					}
+					if !strings.Contains(content, "apiVersion: autoscaling/v2\n") {
+						return &ValidationError{Field: "hpa.yaml", Message: "hpa does not use autoscaling/v2"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "cronjob chart with keda",
+			chartName: "queue-worker",
+			options: map[string]bool{
+				"cronjob": true,
+				"keda":    true,
+			},
+			expectedFiles: []string{
+				"templates/cronjob.yaml",
+				"templates/scaledjob.yaml",
+				"templates/_job.tpl",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					if !strings.Contains(content, "schedule:") {
+						return &ValidationError{Field: "values.yaml", Message: "cronjob schedule not found"}
+					}
+					if !strings.Contains(content, "keda:\n  # -- create a KEDA ScaledJob running the jobs instead of the CronJob (requires keda.triggers)\n  enabled: false") {
+						return &ValidationError{Field: "values.yaml", Message: "keda.enabled not found"}
+					}
+					if !strings.Contains(content, "triggers: []") {
+						return &ValidationError{Field: "values.yaml", Message: "keda triggers not found"}
+					}
+					return nil
+				},
+				"templates/cronjob.yaml": func(content string) error {
+					if !strings.HasPrefix(content, "{{- if not .Values.keda.enabled }}") {
+						return &ValidationError{Field: "cronjob.yaml", Message: "cronjob not disabled by keda.enabled"}
+					}
+					return nil
+				},
+				"templates/scaledjob.yaml": func(content string) error {
+					for _, want := range []string{"{{- if .Values.keda.enabled }}", "{{- fail ", `include "queue-worker.jobPodTemplate" . | nindent 6`} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "scaledjob.yaml", Message: want + " not found"}
+						}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "daemonset chart with vpa",
+			chartName: "node-agent",
+			options: map[string]bool{
+				"daemonset": true,
+				"vpa":       true,
+			},
+			expectedFiles: []string{
+				"templates/daemonset.yaml",
+				"templates/vpa.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/vpa.yaml": func(content string) error {
+					if !strings.Contains(content, "kind: DaemonSet") {
+						return &ValidationError{Field: "vpa.yaml", Message: "vpa does not target the daemonset"}
+					}
+					if !strings.Contains(content, `.Capabilities.APIVersions.Has "autoscaling.k8s.io/v1"`) {
+						return &ValidationError{Field: "vpa.yaml", Message: "capabilities guard not found"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "ingress chart with certificate",
+			chartName: "tls-app",
+			options: map[string]bool{
+				"deployment":  true,
+				"service":     true,
+				"ingress":     true,
+				"certificate": true,
+			},
+			expectedFiles: []string{
+				"templates/ingress.yaml",
+				"templates/certificate.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/ingress.yaml": func(content string) error {
+					if !strings.Contains(content, `secretName: {{ include "tls-app.certificateSecretName" . }}`) {
+						return &ValidationError{Field: "ingress.yaml", Message: "certificate secret not wired into the ingress tls"}
+					}
+					return nil
+				},
+			},
+		},
 		{
 			name:      "full featured chart",
 			chartName: "full-app",
@@ -116,6 +374,476 @@ func TestGenerateChart_Integration(t *testing.T) {
 				"templates/tests",
 			},
 		},
+		{
+			name:      "chart with tcp probes",
+			chartName: "tcp-app",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+			},
+			probeType: ProbeTCP,
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/deployment.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					if !strings.Contains(content, "livenessProbe:\n  tcpSocket:\n    port: http") {
+						return &ValidationError{Field: "values.yaml", Message: "tcp liveness probe not found"}
+					}
+					if !strings.Contains(content, "startupProbe: {}") {
+						return &ValidationError{Field: "values.yaml", Message: "startupProbe not found"}
+					}
+					return nil
+				},
+				"templates/deployment.yaml": func(content string) error {
+					if strings.Contains(content, "httpGet") {
+						return &ValidationError{Field: "deployment.yaml", Message: "probes should be driven from values"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "cronjob chart without probes",
+			chartName: "batch-app",
+			options: map[string]bool{
+				"cronjob": true,
+				"service": true,
+			},
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/cronjob.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					if strings.Contains(content, "Probe") {
+						return &ValidationError{Field: "values.yaml", Message: "cronjob values should not define probes"}
+					}
+					return nil
+				},
+				"templates/_job.tpl": func(content string) error {
+					if strings.Contains(content, "Probe") {
+						return &ValidationError{Field: "_job.tpl", Message: "cronjob should not define probes"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with named ports",
+			chartName: "ports-app",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+			},
+			ports: []Port{{Name: "api", Number: 8080}, {Name: "grpc", Number: 9000}},
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/service.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					for _, want := range []string{
+						"  - name: api\n    containerPort: 8080\n    servicePort: 8080",
+						"  - name: grpc\n    containerPort: 9000\n    servicePort: 9000",
+						"    port: api",
+					} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "values.yaml", Message: "port values not found: " + want}
+						}
+					}
+					return nil
+				},
+				"templates/service.yaml": func(content string) error {
+					if !strings.Contains(content, "range .Values.ports") {
+						return &ValidationError{Field: "service.yaml", Message: "service ports should be driven from values"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with env in every workload",
+			chartName: "env-app",
+			options: map[string]bool{
+				"deployment":  true,
+				"statefulset": true,
+				"daemonset":   true,
+				"cronjob":     true,
+			},
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/_helpers.tpl",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					if !strings.Contains(content, "\nenv: {}\n") {
+						return &ValidationError{Field: "values.yaml", Message: "env values not found"}
+					}
+					return nil
+				},
+				"templates/_helpers.tpl": func(content string) error {
+					if !strings.Contains(content, `define "env-app.env"`) {
+						return &ValidationError{Field: "_helpers.tpl", Message: "env helper not found"}
+					}
+					return nil
+				},
+				"templates/deployment.yaml":  checkEnv("deployment.yaml"),
+				"templates/statefulset.yaml": checkEnv("statefulset.yaml"),
+				"templates/daemonset.yaml":   checkEnv("daemonset.yaml"),
+				"templates/_job.tpl":         checkEnv("_job.tpl"),
+			},
+		},
+		{
+			name:      "chart with configmap checksum",
+			chartName: "checksum-app",
+			options: map[string]bool{
+				"deployment": true,
+				"daemonset":  true,
+				"configmap":  true,
+			},
+			expectedFiles: []string{
+				"templates/configmap.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"templates/deployment.yaml": func(content string) error {
+					if !strings.Contains(content, `checksum/config: {{ include (print $.Template.BasePath "/configmap.yaml") . | sha256sum }}`) {
+						return &ValidationError{Field: "deployment.yaml", Message: "configmap checksum annotation not found"}
+					}
+					return nil
+				},
+				"templates/daemonset.yaml": func(content string) error {
+					if !strings.Contains(content, "checksum/config:") {
+						return &ValidationError{Field: "daemonset.yaml", Message: "configmap checksum annotation not found"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "cronjob chart with zone spread",
+			chartName: "spread-app",
+			options: map[string]bool{
+				"cronjob":    true,
+				"zonespread": true,
+			},
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/cronjob.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					if !strings.Contains(content, "topologySpreadConstraints:\n  - maxSkew: 1\n    topologyKey: topology.kubernetes.io/zone") {
+						return &ValidationError{Field: "values.yaml", Message: "zone spread constraint not found"}
+					}
+					if !strings.Contains(content, "\nterminationGracePeriodSeconds: null\n") {
+						return &ValidationError{Field: "values.yaml", Message: "terminationGracePeriodSeconds not null"}
+					}
+					return nil
+				},
+				"templates/_job.tpl": func(content string) error {
+					for _, want := range []string{`include "spread-app.topologySpreadConstraints" $`, ".Values.priorityClassName", ".Values.dnsConfig", ".Values.hostAliases",
+						`if not (kindIs "invalid" .Values.terminationGracePeriodSeconds)`} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "_job.tpl", Message: want + " not found"}
+						}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with sidecar",
+			chartName: "sidecar-app",
+			options: map[string]bool{
+				"deployment": true,
+			},
+			sidecars: []Sidecar{
+				{Name: "log-shipper", Image: "fluent/fluent-bit:3.0", Port: 2020, MountPath: "/var/log/app"},
+			},
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/deployment.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					for _, want := range []string{
+						"  - name: log-shipper\n    image: fluent/fluent-bit:3.0",
+						"      - containerPort: 2020",
+						"volumes:\n  - name: log-shipper\n    emptyDir: {}",
+						"volumeMounts:\n  - name: log-shipper\n    mountPath: /var/log/app",
+						"initContainers: []",
+						"extraContainers: []",
+					} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "values.yaml", Message: "sidecar values not found: " + want}
+						}
+					}
+					return nil
+				},
+				"templates/deployment.yaml": func(content string) error {
+					for _, want := range []string{".Values.initContainers", ".Values.sidecars", ".Values.extraContainers"} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "deployment.yaml", Message: want + " not found in deployment template"}
+						}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "restricted security profile",
+			chartName: "secure-app",
+			options: map[string]bool{
+				"deployment": true,
+			},
+			securityProfile: SecurityRestricted,
+			expectedFiles: []string{
+				"Chart.yaml",
+				"values.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"Chart.yaml": func(content string) error {
+					if !strings.Contains(content, "helmchart-helper/security-profile: restricted") {
+						return &ValidationError{Field: "Chart.yaml", Message: "security profile annotation not found"}
+					}
+					return nil
+				},
+				"values.yaml": func(content string) error {
+					for _, want := range []string{
+						"  runAsNonRoot: true",
+						"  seccompProfile:\n    type: RuntimeDefault",
+						"  readOnlyRootFilesystem: true",
+						"    drop:\n      - ALL",
+						"volumes:\n  - name: tmp\n    emptyDir: {}",
+						"  - name: tmp\n    mountPath: /tmp",
+					} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "values.yaml", Message: "restricted values not found: " + want}
+						}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with extra objects",
+			chartName: "extra-app",
+			options: map[string]bool{
+				"deployment":   true,
+				"extraobjects": true,
+			},
+			expectedFiles: []string{
+				"values.yaml",
+				"templates/extra-manifests.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					if !strings.Contains(content, "extraObjects: []") {
+						return &ValidationError{Field: "values.yaml", Message: "extraObjects not found"}
+					}
+					return nil
+				},
+				"templates/extra-manifests.yaml": func(content string) error {
+					if !strings.Contains(content, "range .Values.extraObjects") || !strings.Contains(content, "tpl (toYaml .) $") {
+						return &ValidationError{Field: "extra-manifests.yaml", Message: "extraObjects are not rendered through tpl"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "chart with dependencies",
+			chartName: "dep-app",
+			options: map[string]bool{
+				"deployment": true,
+			},
+			dependencies: []Dependency{
+				{Name: "postgresql", Repository: "oci://registry-1.docker.io/bitnamicharts", Version: "15.5.0", Condition: "postgresql.enabled"},
+			},
+			expectedFiles: []string{
+				"Chart.yaml",
+				"values.yaml",
+				".helmignore",
+			},
+			fileChecks: map[string]func(string) error{
+				"Chart.yaml": func(content string) error {
+					if !strings.Contains(content, "dependencies:\n  - name: postgresql\n    version: \"15.5.0\"\n    repository: oci://registry-1.docker.io/bitnamicharts\n    condition: postgresql.enabled") {
+						return &ValidationError{Field: "Chart.yaml", Message: "postgresql dependency not found"}
+					}
+					return nil
+				},
+				"values.yaml": func(content string) error {
+					for _, want := range []string{
+						"dependencyEnv:\n  - name: POSTGRESQL_HOST\n    dependency: postgresql\n    condition: postgresql.enabled",
+						"postgresql:\n  # -- deploy the postgresql dependency\n  enabled: true",
+					} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "values.yaml", Message: "dependency values not found: " + want}
+						}
+					}
+					return nil
+				},
+				".helmignore": func(content string) error {
+					if !strings.Contains(content, "helm dependency build") {
+						return &ValidationError{Field: ".helmignore", Message: "helm dependency build not mentioned"}
+					}
+					return nil
+				},
+				"templates/NOTES.txt": func(content string) error {
+					if !strings.Contains(content, "* postgresql 15.5.0 from oci://registry-1.docker.io/bitnamicharts") {
+						return &ValidationError{Field: "NOTES.txt", Message: "dependency not listed"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "library chart",
+			chartName: "company-lib",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+			},
+			chartType: ChartTypeLibrary,
+			expectedFiles: []string{
+				"Chart.yaml",
+				"values.yaml",
+				"templates/_helpers.tpl",
+				"templates/_deployment.tpl",
+				"templates/_service.tpl",
+			},
+			fileChecks: map[string]func(string) error{
+				"Chart.yaml": func(content string) error {
+					if !strings.Contains(content, "type: library") {
+						return &ValidationError{Field: "Chart.yaml", Message: "library type not found"}
+					}
+					return nil
+				},
+				"templates/_deployment.tpl": func(content string) error {
+					if !strings.HasPrefix(content, `{{- define "company-lib.deployment" }}`) || !strings.HasSuffix(content, "{{- end }}\n") {
+						return &ValidationError{Field: "_deployment.tpl", Message: "deployment is not a named template"}
+					}
+					if !strings.Contains(content, "kind: Deployment") {
+						return &ValidationError{Field: "_deployment.tpl", Message: "deployment manifest not found"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "application using a library chart",
+			chartName: "shop",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+			},
+			library: Dependency{Name: "company-lib", Repository: "oci://registry.example.com/charts", Version: "1.0.0"},
+			expectedFiles: []string{
+				"templates/deployment.yaml",
+				"templates/service.yaml",
+				"templates/NOTES.txt",
+			},
+			fileChecks: map[string]func(string) error{
+				"Chart.yaml": func(content string) error {
+					if !strings.Contains(content, "type: application") ||
+						!strings.Contains(content, "  - name: company-lib\n    version: \"1.0.0\"\n    repository: oci://registry.example.com/charts\n") {
+						return &ValidationError{Field: "Chart.yaml", Message: "library dependency not found"}
+					}
+					return nil
+				},
+				"templates/deployment.yaml": func(content string) error {
+					if content != "{{- include \"company-lib.deployment\" . }}\n" {
+						return &ValidationError{Field: "deployment.yaml", Message: "deployment does not include the library template"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "per-environment values files",
+			chartName: "web",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+				"ingress":    true,
+				"hpa":        true,
+			},
+			environments: []string{"dev", "prod"},
+			expectedFiles: []string{
+				"values-dev.yaml",
+				"values-prod.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values-dev.yaml": func(content string) error {
+					if !strings.Contains(content, "replicaCount: 1\n") ||
+						!strings.Contains(content, "autoscaling:\n  enabled: false\n") ||
+						!strings.Contains(content, "- host: web.dev.local\n") {
+						return &ValidationError{Field: "values-dev.yaml", Message: "dev profile not found"}
+					}
+					return nil
+				},
+				"values-prod.yaml": func(content string) error {
+					if !strings.Contains(content, "replicaCount: 3\n") ||
+						!strings.Contains(content, "  maxReplicas: 10\n") ||
+						!strings.Contains(content, "- host: web.local\n") {
+						return &ValidationError{Field: "values-prod.yaml", Message: "prod profile not found"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "partial environment profile",
+			chartName: "web",
+			options: map[string]bool{
+				"deployment": true,
+			},
+			environments: []string{"qa"},
+			envProfile:   "qa:\n  resources:\n    limits:\n      memory: 256Mi\n",
+			expectedFiles: []string{
+				"values-qa.yaml",
+			},
+			fileChecks: map[string]func(string) error{
+				"values-qa.yaml": func(content string) error {
+					if strings.Contains(content, "replicaCount") || strings.Contains(content, `""`) ||
+						!strings.Contains(content, "resources:\n  limits:\n    memory: \"256Mi\"\n") {
+						return &ValidationError{Field: "values-qa.yaml", Message: "unset fields of the qa profile are written"}
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:      "defaults from a Dockerfile",
+			chartName: "api",
+			options: map[string]bool{
+				"deployment": true,
+				"service":    true,
+			},
+			dockerfile: "FROM alpine:3.20\nENV EXAMPLE_DIR=/srv/example\nEXPOSE 53/udp 3000\n" +
+				"VOLUME /data\nUSER 1001\nHEALTHCHECK --interval=10s CMD [\"/app\", \"health\"]\n",
+			expectedFiles: []string{"values.yaml"},
+			fileChecks: map[string]func(string) error{
+				"values.yaml": func(content string) error {
+					for _, want := range []string{
+						"  repository: api\n",
+						"  - name: http\n    containerPort: 3000\n",
+						"  - name: udp-53\n    containerPort: 53\n    servicePort: 53\n    protocol: UDP\n",
+						"livenessProbe:\n  exec:\n    command:\n      - \"/app\"\n      - \"health\"\n  periodSeconds: 10\n",
+						"securityContext:\n  runAsNonRoot: true\n  runAsUser: 1001\n",
+						"env:\n  EXAMPLE_DIR: \"/srv/example\"\n",
+						"volumeMounts:\n  - name: data\n    mountPath: /data\n",
+					} {
+						if !strings.Contains(content, want) {
+							return &ValidationError{Field: "values.yaml", Message: "Dockerfile setting not found: " + want}
+						}
+					}
+					return nil
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -162,6 +890,55 @@ func TestGenerateChart_Integration(t *testing.T) {
 			if tt.options["volumes"] {
 				app.SetVolumes(true)
 			}
+			if tt.options["certificate"] {
+				app.SetCertificate(true)
+			}
+			if tt.options["httproute"] {
+				app.SetHTTPRoute(true)
+			}
+			if tt.options["keda"] {
+				app.SetKeda(true)
+			}
+			if tt.options["vpa"] {
+				app.SetVpa(true)
+			}
+			if tt.options["metrics"] {
+				app.SetMetrics(true)
+			}
+			if tt.options["zonespread"] {
+				app.SetZoneSpread(true)
+			}
+			if tt.options["extraobjects"] {
+				app.SetExtraObjects(true)
+			}
+			if tt.probeType != "" {
+				app.SetProbe(tt.probeType, "")
+			}
+			if tt.ports != nil {
+				app.SetPorts(tt.ports)
+			}
+			if tt.sidecars != nil {
+				app.SetSidecars(tt.sidecars)
+			}
+			app.SetSecurityProfile(tt.securityProfile)
+			app.SetDependencies(tt.dependencies)
+			app.SetChartType(tt.chartType)
+			app.SetLibrary(tt.library)
+			app.SetEnvironments(tt.environments)
+			if tt.envProfile != "" {
+				profile := filepath.Join(tempDir, tt.name+".yaml")
+				if err := os.WriteFile(profile, []byte(tt.envProfile), 0644); err != nil {
+					t.Fatal(err)
+				}
+				app.SetEnvironmentProfile(profile)
+			}
+			if tt.dockerfile != "" {
+				dockerfile := filepath.Join(tempDir, tt.name+".Dockerfile")
+				if err := os.WriteFile(dockerfile, []byte(tt.dockerfile), 0644); err != nil {
+					t.Fatal(err)
+				}
+				app.SetDockerfile(dockerfile)
+			}
 
 			// Generate chart
 			err := app.GenerateChart()
@@ -203,6 +980,151 @@ func TestGenerateChart_Integration(t *testing.T) {
 	}
 }
 
+func TestGenerateChart_ConfigFiles(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "helmchart-config-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	confDir := filepath.Join(tempDir, "conf")
+	if err := os.MkdirAll(filepath.Join(confDir, "nested"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	sources := map[string]string{
+		filepath.Join(confDir, "nginx.conf"):          "server_name example.com;\n",
+		filepath.Join(confDir, "nested", "skip.conf"): "skipped\n",
+		filepath.Join(tempDir, "app.properties"):      "name=example\n",
+	}
+	for name, content := range sources {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	chartDir := filepath.Join(tempDir, "chart")
+	app := NewApp("config-app", chartDir, filesystem.NewOSFileSystem(), filesystem.NewDefaultTemplateProcessor(), filesystem.NewDefaultPathManager(), GetChartTemplate())
+	app.SetDeployment(true)
+	app.SetConfigFiles([]string{filepath.Join(tempDir, "app.properties")})
+	app.SetConfigDir(confDir)
+	if err := app.GenerateChart(); err != nil {
+		t.Fatalf("GenerateChart() failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"files/nginx.conf":     "server_name example.com;\n",
+		"files/app.properties": "name=example\n",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(chartDir, name))
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q (files must be copied verbatim)", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, "files", "skip.conf")); !os.IsNotExist(err) {
+		t.Errorf("nested files should not be copied")
+	}
+
+	configMap, err := os.ReadFile(filepath.Join(chartDir, "templates", "configmap-files.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read configmap-files.yaml: %v", err)
+	}
+	if !strings.Contains(string(configMap), `(.Files.Glob "files/*").AsConfig`) {
+		t.Errorf("configmap-files.yaml should be fed by .Files.Glob")
+	}
+
+	deployment, err := os.ReadFile(filepath.Join(chartDir, "templates", "deployment.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read deployment.yaml: %v", err)
+	}
+	for _, want := range []string{"checksum/config-files", "name: config-files", ".Values.configFiles.mountPath"} {
+		if !strings.Contains(string(deployment), want) {
+			t.Errorf("deployment.yaml should contain %q", want)
+		}
+	}
+}
+
+// checkEnv returns a file check asserting that a workload renders the env
+// helper.
+func checkEnv(file string) func(string) error {
+	return func(content string) error {
+		if !strings.Contains(content, `include "env-app.env" $`) {
+			return &ValidationError{Field: file, Message: "env helper not included"}
+		}
+		return nil
+	}
+}
+
+func TestGenerateUmbrella(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "helmchart-umbrella-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	chartDir := filepath.Join(tempDir, "product")
+	umbrella := NewUmbrella("product", chartDir, filesystem.NewOSFileSystem(), filesystem.NewDefaultTemplateProcessor(), filesystem.NewDefaultPathManager(), GetChartTemplate())
+	if err := umbrella.GenerateChart(); err == nil || !strings.Contains(err.Error(), "at least one component") {
+		t.Fatalf("GenerateChart() without component error = %v", err)
+	}
+	api := umbrella.AddComponent("api")
+	api.SetDeployment(true)
+	api.SetService(true)
+	umbrella.AddComponent("worker").SetCronjob(true)
+	if err := umbrella.GenerateChart(); err != nil {
+		t.Fatalf("GenerateChart() failed: %v", err)
+	}
+
+	checks := map[string][]string{
+		"Chart.yaml": {
+			"name: product",
+			"  - name: api\n    version: 0.1.0\n    condition: api.enabled",
+			"  - name: worker\n    version: 0.1.0\n    condition: worker.enabled",
+		},
+		"values.yaml": {
+			"global:\n",
+			"  imageRegistry: \"\"",
+			"api:\n  # -- deploy the api component\n  enabled: true",
+			"worker:\n  # -- deploy the worker component\n  enabled: true",
+		},
+		"templates/NOTES.txt": {
+			`if (index .Values "api").enabled`,
+		},
+		"charts/api/Chart.yaml": {
+			"name: api",
+		},
+		"charts/api/templates/deployment.yaml": {
+			`include "api.image" .`,
+			`include "api.imagePullSecrets" .`,
+		},
+		"charts/api/templates/_helpers.tpl": {
+			"(.Values.global | default dict).imageRegistry",
+		},
+		"charts/worker/templates/_job.tpl": {
+			`include "worker.image" .`,
+		},
+	}
+	for name, wants := range checks {
+		content, err := os.ReadFile(filepath.Join(chartDir, name))
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", name, err)
+			continue
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s does not contain %q", name, want)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, "charts", "worker", "templates", "service.yaml")); !os.IsNotExist(err) {
+		t.Errorf("worker component should not have a service")
+	}
+}
+
 func TestGenerateChart_ErrorHandling(t *testing.T) {
 	// Use a read-only temp directory to trigger real filesystem errors
 	tempDir, err := os.MkdirTemp("", "helmchart-error-test-*")
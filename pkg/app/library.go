@@ -0,0 +1,79 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// Chart types of the generated chart.
+const (
+	ChartTypeApplication = "application"
+	ChartTypeLibrary     = "library"
+)
+
+// ChartTypes lists the supported chart types.
+var ChartTypes = []string{ChartTypeApplication, ChartTypeLibrary}
+
+// IsLibrary reports whether a library chart is generated: the resources are
+// named templates (<chart>.<resource>) instead of manifests.
+func (o options) IsLibrary() bool {
+	return o.ChartType == ChartTypeLibrary
+}
+
+// UsesLibrary reports whether the resources of the application include the
+// named templates of a library chart instead of inlining them.
+func (o options) UsesLibrary() bool {
+	return o.Library.Name != ""
+}
+
+// resourceName returns the name of a resource from its manifest path
+// (templates/deployment.yaml is deployment).
+func resourceName(outputPath string) string {
+	return strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath))
+}
+
+// createDefineFromTemplate renders a resource template as the named template
+// example.<resource> in templates/_<resource>.tpl of a library chart.
+func (a *App) createDefineFromTemplate(templatePath string, outputPath string) error {
+	tmpl, err := a.templateProcessor.ParseFS(a.chartTemplateFS, templatePath)
+	if err != nil {
+		return errors.NewTemplateError("parse-template", "failed to parse template", err).
+			WithChart(a.opts.ChartName).
+			WithFile(templatePath)
+	}
+	content, err := a.templateProcessor.Execute(tmpl, a.opts)
+	if err != nil {
+		return errors.NewTemplateError("execute-template", "failed to execute template", err).
+			WithChart(a.opts.ChartName).
+			WithFile(outputPath).
+			WithContext("template", templatePath)
+	}
+
+	name := resourceName(outputPath)
+	definePath := filepath.Join(filepath.Dir(outputPath), "_"+name+".tpl")
+	define := "{{- define \"example." + name + "\" }}\n" +
+		strings.TrimRight(string(content), "\n") + "\n{{- end }}\n"
+	const filePerm = 0644
+	if err := a.fs.WriteFile(definePath, []byte(define), filePerm); err != nil {
+		return errors.NewFileSystemError("write-file", "failed to write output file", err).
+			WithChart(a.opts.ChartName).
+			WithFile(definePath).
+			WithContext("template", templatePath)
+	}
+	return nil
+}
+
+// createLibraryInclude writes a resource manifest including the named template
+// of the library chart.
+func (a *App) createLibraryInclude(outputPath string) error {
+	include := "{{- include \"" + a.opts.Library.Name + "." + resourceName(outputPath) + "\" . }}\n"
+	const filePerm = 0644
+	if err := a.fs.WriteFile(outputPath, []byte(include), filePerm); err != nil {
+		return errors.NewFileSystemError("write-file", "failed to write output file", err).
+			WithChart(a.opts.ChartName).
+			WithFile(outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"embed"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
+)
+
+// umbrellaChartsDir is the directory of the umbrella chart holding the
+// component charts.
+const umbrellaChartsDir = "charts"
+
+// Umbrella generates an umbrella chart: a parent chart declaring one
+// dependency per component, the component charts under charts/ and the
+// global values shared by the components.
+type Umbrella struct {
+	parent     *App
+	components []*App
+}
+
+// NewUmbrella creates a new umbrella chart generator.
+func NewUmbrella(chartName string, chartPath string, fs interfaces.FileSystem, templateProcessor interfaces.TemplateProcessor, pathManager interfaces.PathManager, chartTemplateFS embed.FS) *Umbrella {
+	return &Umbrella{
+		parent: NewApp(chartName, chartPath, fs, templateProcessor, pathManager, chartTemplateFS),
+	}
+}
+
+// AddComponent adds a component chart generated in charts/<name> and returns
+// it so that its resources can be selected.
+func (u *Umbrella) AddComponent(name string) *App {
+	p := u.parent
+	component := NewApp(name, p.pathManager.Join(p.chartPath, umbrellaChartsDir, name),
+		p.fs, p.templateProcessor, p.pathManager, p.chartTemplateFS)
+	u.components = append(u.components, component)
+	p.opts.Components = append(p.opts.Components, name)
+	return component
+}
+
+// GenerateChart generates the parent chart then every component chart.
+func (u *Umbrella) GenerateChart() error {
+	p := u.parent
+	if len(u.components) == 0 {
+		return errors.NewValidationError("generate-umbrella", "an umbrella chart needs at least one component").
+			WithChart(p.opts.ChartName)
+	}
+
+	templatesDir := p.pathManager.Join(p.chartPath, "templates")
+	const dirPerm = 0755
+	if err := p.fs.MkdirAll(templatesDir, dirPerm); err != nil {
+		return errors.NewFileSystemError("create-directory", "failed to create templates directory", err).
+			WithChart(p.opts.ChartName).
+			WithFile(templatesDir)
+	}
+	if err := p.createFileFromTemplate("chartTemplate/helmignore", p.pathManager.Join(p.chartPath, ".helmignore")); err != nil {
+		return err
+	}
+	files := []struct {
+		template   string
+		outputFile string
+	}{
+		{"chartTemplate/umbrella/Chart.yaml", p.pathManager.Join(p.chartPath, "Chart.yaml")},
+		{"chartTemplate/umbrella/values.yaml", p.pathManager.Join(p.chartPath, "values.yaml")},
+		{"chartTemplate/umbrella/NOTES.txt", p.pathManager.Join(templatesDir, "NOTES.txt")},
+	}
+	for _, f := range files {
+		if err := p.createFileFromTemplate(f.template, f.outputFile); err != nil {
+			return err
+		}
+	}
+
+	for _, component := range u.components {
+		if err := component.GenerateChart(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
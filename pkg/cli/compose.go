@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// ComposeConfig holds the configuration of the from-compose command:
+//
+//	helmchart-helper from-compose [-f docker-compose.yml] -n name -o path [-service name]...
+type ComposeConfig struct {
+	ComposeFile string
+	ChartName   string
+	OutputDir   string
+	// Services are the converted services, all of them when empty.
+	Services []string
+	Help     bool
+}
+
+// ParseComposeFlagsFromArgs parses the arguments following the from-compose
+// command.
+func ParseComposeFlagsFromArgs(args []string) (*ComposeConfig, error) {
+	config := &ComposeConfig{}
+	flagSet := flag.NewFlagSet("from-compose", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.ComposeFile, "f", "",
+		"Compose file, compose.yaml or docker-compose.yml of the current directory by default")
+	flagSet.StringVar(&config.ChartName, "n", "", "Name of the chart")
+	flagSet.StringVar(&config.OutputDir, "o", "", "Path of the generated chart")
+	flagSet.Var(listFlag{&config.Services}, "service", "service to convert, all of them by default (repeatable)")
+	flagSet.BoolVar(&config.Help, "help", false, "Print help")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if flagSet.NArg() > 0 {
+		return nil, errors.NewValidationError("parse-compose-flags", "unexpected argument "+flagSet.Arg(0))
+	}
+
+	return config, nil
+}
+
+// Validate validates the from-compose configuration.
+func (c *ComposeConfig) Validate() error {
+	if err := validateChartName(c.ChartName); err != nil {
+		return err
+	}
+	if c.OutputDir == "" {
+		return errors.NewValidationError("validate-compose", "chart path is required").
+			WithContext("flag", "-o")
+	}
+	return nil
+}
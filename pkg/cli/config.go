@@ -7,6 +7,30 @@
 //     letter, contain only lowercase letters, numbers, and hyphens, max 253 chars
 //   - Output directory (-o) is required and must be non-empty
 //   - All resource flags are optional and default to false
+//   - HPA (-hpa) requires a Deployment (-deploy) or a StatefulSet (-sts)
+//   - KEDA (-keda) requires a Deployment, a StatefulSet or a CronJob and cannot
+//     be combined with HPA (-hpa) since both would manage the replicas
+//   - VPA (-vpa) requires a Deployment, a StatefulSet, a DaemonSet or a CronJob
+//   - Certificate (-cert) requires an Ingress (-ing)
+//   - HTTPRoute (-httproute) requires a Service (-svc) and cannot be combined
+//     with Ingress (-ing) unless -allow-ing-httproute is set
+//   - Probe type (-probe-type) is http, tcp, exec or grpc and the probe path
+//     (-probe-path) must start with a slash
+//   - Ports (-port name:number) need a valid port name (at most 15 characters)
+//     and a number between 1 and 65535, names and numbers are unique and the
+//     name metrics and the metrics port number are reserved when -metrics is set
+//   - Security profile (-security-profile) is restricted, baseline or none
+//   - Dependencies (-dependency name=repository@version[,condition=path]) need
+//     a valid chart name, an http(s), oci or file repository and a version,
+//     their names and conditions do not use a values key of the chart and
+//     their conditions are values paths that do not overlap each other
+//   - Chart type (-type) is application or library, a library chart cannot
+//     use a library (-library name=repository@version) itself
+//   - Environments (-env) are lowercase DNS labels declared once, the
+//     environment profile (-env-profile) requires environments
+//   - Sidecars (-sidecar name=image[,port=N][,mount=/path]) need a valid
+//     container name other than the chart name, tmp, data or config-files, a
+//     port between 1 and 65535 and an absolute mount path
 //
 // Error Handling:
 //   - Invalid flags return a wrapped error from flag.Parse
@@ -19,8 +43,11 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/sgaunet/helmchart-helper/pkg/app"
 	"github.com/sgaunet/helmchart-helper/pkg/errors"
 )
 
@@ -33,20 +60,41 @@ const maxChartNameLength = 253
 
 // Config holds all CLI configuration.
 type Config struct {
-	ChartName      string
-	OutputDir      string
-	Deployment     bool
-	Hpa            bool
-	StatefulSet    bool
-	DaemonSet      bool
-	Cronjob        bool
-	Configmap      bool
-	Service        bool
-	ServiceAccount bool
-	Ingress        bool
-	Volumes        bool
-	Version        bool
-	Help           bool
+	ChartName             string
+	OutputDir             string
+	Deployment            bool
+	Hpa                   bool
+	StatefulSet           bool
+	DaemonSet             bool
+	Cronjob               bool
+	Configmap             bool
+	Service               bool
+	ServiceAccount        bool
+	Ingress               bool
+	HTTPRoute             bool
+	Certificate           bool
+	Volumes               bool
+	Metrics               bool
+	Keda                  bool
+	Vpa                   bool
+	AllowIngressHTTPRoute bool
+	Sidecars              []app.Sidecar
+	ProbeType             string
+	ProbePath             string
+	Ports                 []app.Port
+	ConfigFiles           []string
+	ConfigDir             string
+	ZoneSpread            bool
+	SecurityProfile       string
+	ExtraObjects          bool
+	Dependencies          []app.Dependency
+	ChartType             string
+	Library               app.Dependency
+	Environments          []string
+	EnvProfile            string
+	FromDockerfile        string
+	Version               bool
+	Help                  bool
 }
 
 // ParseFlags parses command line flags and returns Config.
@@ -63,15 +111,36 @@ func ParseFlagsFromArgs(args []string) (*Config, error) {
 	flagSet.StringVar(&config.OutputDir, "o", "", "Path of the generated chart")
 	
 	flagSet.BoolVar(&config.Hpa, "hpa", false, "hpa")
+	flagSet.BoolVar(&config.Keda, "keda", false, "keda scaledobject (deployment/statefulset) or scaledjob (replaces the cronjob when enabled)")
+	flagSet.BoolVar(&config.Vpa, "vpa", false, "vertical pod autoscaler")
 	flagSet.BoolVar(&config.StatefulSet, "sts", false, "statefulset")
 	flagSet.BoolVar(&config.DaemonSet, "ds", false, "daemonset")
 	flagSet.BoolVar(&config.Cronjob, "cj", false, "cronjob")
+	flagSet.Var(dependencyFlag{&config.Dependencies}, "dependency", "chart dependency name=repository@version[,condition=path], its service host is set in the env (repeatable)")
 	flagSet.BoolVar(&config.Deployment, "deploy", false, "deployment")
 	flagSet.BoolVar(&config.Configmap, "cm", false, "configmap")
+	flagSet.Var(stringsFlag{&config.ConfigFiles}, "config-from-file", "copy a configuration file into files/ and mount it from a configmap (repeatable)")
+	flagSet.StringVar(&config.ConfigDir, "config-from-dir", "", "copy the files of a directory into files/ and mount them from a configmap")
+	flagSet.Var(libraryFlag{&config.Library}, "library", "library chart name=repository@version whose named templates are included instead of inlining the resources")
+	flagSet.Var(listFlag{&config.Environments}, "env", "environments whose values-<env>.yaml overlay is generated, e.g. dev,staging,prod (built-in profiles)")
+	flagSet.StringVar(&config.EnvProfile, "env-profile", "", "YAML file of the environment profiles (replicaCount, resources, autoscaling, ingressHost) overriding the built-in ones")
 	flagSet.BoolVar(&config.Ingress, "ing", false, "ingress")
+	flagSet.BoolVar(&config.Certificate, "cert", false, "cert-manager certificate for the ingress hosts")
+	flagSet.BoolVar(&config.HTTPRoute, "httproute", false, "gateway api httproute")
+	flagSet.BoolVar(&config.AllowIngressHTTPRoute, "allow-ing-httproute", false, "allow generating both ingress and httproute")
 	flagSet.BoolVar(&config.Volumes, "pv", false, "volumes")
 	flagSet.BoolVar(&config.Service, "svc", false, "service")
 	flagSet.BoolVar(&config.ServiceAccount, "sa", false, "serviceaccount")
+	flagSet.BoolVar(&config.ExtraObjects, "extra-objects", false, "extra-manifests.yaml template rendering the extraObjects values through tpl")
+	flagSet.BoolVar(&config.Metrics, "metrics", false, "metrics port and prometheus servicemonitor/podmonitor")
+	flagSet.StringVar(&config.ProbeType, "probe-type", app.ProbeHTTP, "type of the default probes: http, tcp, exec or grpc")
+	flagSet.StringVar(&config.ProbePath, "probe-path", "/", "path of the default http probes")
+	flagSet.BoolVar(&config.ZoneSpread, "zone-spread", false, "topology spread constraint spreading the pods across zones")
+	flagSet.Var(portFlag{&config.Ports}, "port", "named port of the main container name:number, the first one is the default port (repeatable)")
+	flagSet.StringVar(&config.SecurityProfile, "security-profile", app.SecurityNone, "security contexts satisfying a pod security standard: restricted, baseline or none")
+	flagSet.StringVar(&config.ChartType, "type", app.ChartTypeApplication, "type of the chart: application or library (named templates of the resources)")
+	flagSet.StringVar(&config.FromDockerfile, "from-dockerfile", "", "Dockerfile of the image whose EXPOSE, HEALTHCHECK, USER, VOLUME and ENV fill the ports, probes, security context, volumes and env")
+	flagSet.Var(sidecarFlag{&config.Sidecars}, "sidecar", "sidecar container name=image[,port=N][,mount=/path] (repeatable)")
 	
 	flagSet.BoolVar(&config.Version, "version", false, "Print version")
 	flagSet.BoolVar(&config.Help, "help", false, "Print help")
@@ -94,6 +163,137 @@ func (c *Config) Validate() error {
 			WithContext("flag", "-o")
 	}
 
+	return c.validateResources()
+}
+
+// validateResources checks that the requested resource combination is consistent.
+func (c *Config) validateResources() error {
+	if c.ProbeType != "" && !slices.Contains(app.ProbeTypes, c.ProbeType) {
+		return errors.NewValidationError("validate-config",
+			"probe type must be one of "+strings.Join(app.ProbeTypes, ", ")).
+			WithContext("flag", "-probe-type").
+			WithContext("value", c.ProbeType)
+	}
+
+	if c.ProbePath != "" && !strings.HasPrefix(c.ProbePath, "/") {
+		return errors.NewValidationError("validate-config", "probe path must start with /").
+			WithContext("flag", "-probe-path").
+			WithContext("value", c.ProbePath)
+	}
+
+	if c.SecurityProfile != "" && !slices.Contains(app.SecurityProfiles, c.SecurityProfile) {
+		return errors.NewValidationError("validate-config",
+			"security profile must be one of "+strings.Join(app.SecurityProfiles, ", ")).
+			WithContext("flag", "-security-profile").
+			WithContext("value", c.SecurityProfile)
+	}
+
+	if c.Metrics && slices.ContainsFunc(c.Ports, func(p app.Port) bool { return p.Name == "metrics" }) {
+		return errors.NewValidationError("validate-config",
+			"port name metrics is reserved for the metrics port").
+			WithContext("flag", "-port")
+	}
+
+	if slices.ContainsFunc(c.Sidecars, func(s app.Sidecar) bool { return s.Name == c.ChartName }) {
+		return errors.NewValidationError("validate-config",
+			"sidecar name must differ from the chart name used by the main container").
+			WithContext("flag", "-sidecar").
+			WithContext("value", c.ChartName)
+	}
+
+	if c.Metrics && slices.ContainsFunc(c.Ports, func(p app.Port) bool { return p.Number == app.MetricsPort }) {
+		return errors.NewValidationError("validate-config",
+			fmt.Sprintf("port %d is reserved for the metrics port", app.MetricsPort)).
+			WithContext("flag", "-port")
+	}
+
+	if c.Hpa && !c.Deployment && !c.StatefulSet {
+		return errors.NewValidationError("validate-config",
+			"hpa can only scale a deployment or a statefulset").
+			WithContext("flag", "-hpa")
+	}
+
+	if c.Keda && c.Hpa {
+		return errors.NewValidationError("validate-config",
+			"keda and hpa are mutually exclusive, both would manage the replicas").
+			WithContext("flag", "-keda")
+	}
+
+	if c.Keda && !c.Deployment && !c.StatefulSet && !c.Cronjob {
+		return errors.NewValidationError("validate-config",
+			"keda requires a deployment, a statefulset or a cronjob").
+			WithContext("flag", "-keda")
+	}
+
+	if c.Vpa && !c.Deployment && !c.StatefulSet && !c.DaemonSet && !c.Cronjob {
+		return errors.NewValidationError("validate-config",
+			"vpa requires a deployment, a statefulset, a daemonset or a cronjob").
+			WithContext("flag", "-vpa")
+	}
+
+	if c.Certificate && !c.Ingress {
+		return errors.NewValidationError("validate-config", "cert requires an ingress").
+			WithContext("flag", "-cert")
+	}
+
+	if c.HTTPRoute && !c.Service {
+		return errors.NewValidationError("validate-config", "httproute requires a service").
+			WithContext("flag", "-httproute")
+	}
+
+	if c.HTTPRoute && c.Ingress && !c.AllowIngressHTTPRoute {
+		return errors.NewValidationError("validate-config",
+			"ingress and httproute are mutually exclusive, use -allow-ing-httproute to generate both").
+			WithContext("flag", "-httproute")
+	}
+
+	if c.ChartType != "" && !slices.Contains(app.ChartTypes, c.ChartType) {
+		return errors.NewValidationError("validate-config",
+			"chart type must be one of "+strings.Join(app.ChartTypes, ", ")).
+			WithContext("flag", "-type").
+			WithContext("value", c.ChartType)
+	}
+
+	if c.Library.Name != "" {
+		if c.ChartType == app.ChartTypeLibrary {
+			return errors.NewValidationError("validate-config", "a library chart cannot use a library").
+				WithContext("flag", "-library")
+		}
+		for _, d := range c.Dependencies {
+			if d.Name == c.Library.Name {
+				return errors.NewValidationError("validate-config",
+					fmt.Sprintf("library %q is also declared as a dependency", d.Name)).
+					WithContext("flag", "-library")
+			}
+		}
+	}
+
+	if err := validateEnvironments(c.Environments, c.EnvProfile); err != nil {
+		return err
+	}
+
+	return validateDependencyConditions(c.Dependencies)
+}
+
+// validateEnvironments checks the environment names and their profile.
+func validateEnvironments(environments []string, profile string) error {
+	for i, env := range environments {
+		if len(env) > maxContainerNameLength || !containerNameRegexp.MatchString(env) {
+			return errors.NewValidationError("validate-config",
+				"environment must contain only lowercase letters, numbers, and hyphens").
+				WithContext("flag", "-env").
+				WithContext("value", env)
+		}
+		if slices.Contains(environments[:i], env) {
+			return errors.NewValidationError("validate-config",
+				fmt.Sprintf("environment %q is declared more than once", env)).
+				WithContext("flag", "-env")
+		}
+	}
+	if profile != "" && len(environments) == 0 {
+		return errors.NewValidationError("validate-config", "environment profile requires environments").
+			WithContext("flag", "-env-profile")
+	}
 	return nil
 }
 
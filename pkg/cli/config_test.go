@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -112,6 +115,329 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr:     true,
 			errContains: "chart name must start with a lowercase letter",
 		},
+		{
+			name: "hpa with statefulset",
+			config: Config{
+				ChartName:   "test-chart",
+				OutputDir:   "/tmp/test",
+				Hpa:         true,
+				StatefulSet: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "hpa with daemonset only",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Hpa:       true,
+				DaemonSet: true,
+			},
+			wantErr:     true,
+			errContains: "hpa can only scale a deployment or a statefulset",
+		},
+		{
+			name: "hpa with cronjob only",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Hpa:       true,
+				Cronjob:   true,
+			},
+			wantErr:     true,
+			errContains: "hpa can only scale a deployment or a statefulset",
+		},
+		{
+			name: "hpa without workload",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Hpa:       true,
+			},
+			wantErr:     true,
+			errContains: "hpa can only scale a deployment or a statefulset",
+		},
+		{
+			name: "hpa with service only",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Hpa:       true,
+				Service:   true,
+			},
+			wantErr:     true,
+			errContains: "hpa can only scale a deployment or a statefulset",
+		},
+		{
+			name: "hpa with deployment and daemonset",
+			config: Config{
+				ChartName:  "test-chart",
+				OutputDir:  "/tmp/test",
+				Hpa:        true,
+				Deployment: true,
+				DaemonSet:  true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "keda with deployment",
+			config: Config{
+				ChartName:  "test-chart",
+				OutputDir:  "/tmp/test",
+				Keda:       true,
+				Deployment: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "keda with cronjob",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Keda:      true,
+				Cronjob:   true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "keda and hpa",
+			config: Config{
+				ChartName:  "test-chart",
+				OutputDir:  "/tmp/test",
+				Keda:       true,
+				Hpa:        true,
+				Deployment: true,
+			},
+			wantErr:     true,
+			errContains: "keda and hpa are mutually exclusive",
+		},
+		{
+			name: "keda with daemonset only",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Keda:      true,
+				DaemonSet: true,
+			},
+			wantErr:     true,
+			errContains: "keda requires a deployment, a statefulset or a cronjob",
+		},
+		{
+			name: "vpa with daemonset",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Vpa:       true,
+				DaemonSet: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "vpa without workload",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Vpa:       true,
+			},
+			wantErr:     true,
+			errContains: "vpa requires a deployment, a statefulset, a daemonset or a cronjob",
+		},
+		{
+			name: "vpa with cronjob and keda",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Vpa:       true,
+				Cronjob:   true,
+				Keda:      true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "sidecar named after the chart",
+			config: Config{
+				ChartName:  "test-chart",
+				OutputDir:  "/tmp/test",
+				Deployment: true,
+				Sidecars:   []app.Sidecar{{Name: "test-chart", Image: "busybox"}},
+			},
+			wantErr:     true,
+			errContains: "sidecar name must differ from the chart name",
+		},
+		{
+			name: "cert with ingress",
+			config: Config{
+				ChartName:   "test-chart",
+				OutputDir:   "/tmp/test",
+				Ingress:     true,
+				Certificate: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "cert without ingress",
+			config: Config{
+				ChartName:   "test-chart",
+				OutputDir:   "/tmp/test",
+				Certificate: true,
+			},
+			wantErr:     true,
+			errContains: "cert requires an ingress",
+		},
+		{
+			name: "httproute with service",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Service:   true,
+				HTTPRoute: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "httproute without service",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				HTTPRoute: true,
+			},
+			wantErr:     true,
+			errContains: "httproute requires a service",
+		},
+		{
+			name: "ingress and httproute without allow",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Service:   true,
+				Ingress:   true,
+				HTTPRoute: true,
+			},
+			wantErr:     true,
+			errContains: "ingress and httproute are mutually exclusive",
+		},
+		{
+			name: "ingress and httproute explicitly allowed",
+			config: Config{
+				ChartName:             "test-chart",
+				OutputDir:             "/tmp/test",
+				Service:               true,
+				Ingress:               true,
+				HTTPRoute:             true,
+				AllowIngressHTTPRoute: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "tcp probe type",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				ProbeType: "tcp",
+				ProbePath: "/",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown probe type",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				ProbeType: "udp",
+			},
+			wantErr:     true,
+			errContains: "probe type must be one of http, tcp, exec, grpc",
+		},
+		{
+			name: "relative probe path",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				ProbePath: "healthz",
+			},
+			wantErr:     true,
+			errContains: "probe path must start with /",
+		},
+		{
+			name: "metrics port name reserved",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Metrics:   true,
+				Ports:     []app.Port{{Name: "metrics", Number: 9090}},
+			},
+			wantErr:     true,
+			errContains: "port name metrics is reserved",
+		},
+		{
+			name: "metrics port number reserved",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Metrics:   true,
+				Ports:     []app.Port{{Name: "web", Number: 9090}},
+			},
+			wantErr:     true,
+			errContains: "port 9090 is reserved for the metrics port",
+		},
+		{
+			name: "dependency named after a values key",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Dependencies: []app.Dependency{
+					{Name: "ingress", Repository: "https://charts", Version: "1.0.0", Condition: "ingress.enabled"},
+				},
+			},
+			wantErr:     true,
+			errContains: `dependency "ingress" uses the values key "ingress" of the chart`,
+		},
+		{
+			name: "dependency condition under a values key",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Dependencies: []app.Dependency{
+					{Name: "redis", Repository: "https://charts", Version: "1.0.0", Condition: "global.redis"},
+				},
+			},
+			wantErr:     true,
+			errContains: `dependency "redis" uses the values key "global" of the chart`,
+		},
+		{
+			name: "dependency condition overlapping another dependency",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				Dependencies: []app.Dependency{
+					{Name: "postgresql", Repository: "https://charts", Version: "1.0.0", Condition: "postgresql.enabled"},
+					{Name: "redis", Repository: "https://charts", Version: "1.0.0", Condition: "postgresql"},
+				},
+			},
+			wantErr:     true,
+			errContains: "overlaps the values of dependency",
+		},
+		{
+			name: "invalid chart type",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				ChartType: "umbrella",
+			},
+			wantErr:     true,
+			errContains: "chart type must be one of application, library",
+		},
+		{
+			name: "library chart using a library",
+			config: Config{
+				ChartName: "test-chart",
+				OutputDir: "/tmp/test",
+				ChartType: "library",
+				Library:   app.Dependency{Name: "lib", Repository: "https://charts", Version: "1.0.0"},
+			},
+			wantErr:     true,
+			errContains: "a library chart cannot use a library",
+		},
 		{
 			name: "special characters",
 			config: Config{
@@ -229,6 +555,36 @@ func TestParseFlagsFromArgs(t *testing.T) {
 				Ingress:    true,
 			},
 		},
+		{
+			name: "with zone spread flag",
+			args: []string{"-n", "test-chart", "-o", "/tmp/test", "-deploy", "-zone-spread"},
+			expected: Config{
+				ChartName:  "test-chart",
+				OutputDir:  "/tmp/test",
+				Deployment: true,
+				ZoneSpread: true,
+			},
+		},
+		{
+			name: "with metrics flag",
+			args: []string{"-n", "test-chart", "-o", "/tmp/test", "-deploy", "-metrics"},
+			expected: Config{
+				ChartName:  "test-chart",
+				OutputDir:  "/tmp/test",
+				Deployment: true,
+				Metrics:    true,
+			},
+		},
+		{
+			name: "with dockerfile flag",
+			args: []string{"-n", "test-chart", "-o", "/tmp/test", "-deploy", "-from-dockerfile", "app/Dockerfile"},
+			expected: Config{
+				ChartName:      "test-chart",
+				OutputDir:      "/tmp/test",
+				Deployment:     true,
+				FromDockerfile: "app/Dockerfile",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -256,6 +612,644 @@ func TestParseFlagsFromArgs(t *testing.T) {
 			if config.Ingress != tt.expected.Ingress {
 				t.Errorf("Ingress = %v, want %v", config.Ingress, tt.expected.Ingress)
 			}
+			if config.Metrics != tt.expected.Metrics {
+				t.Errorf("Metrics = %v, want %v", config.Metrics, tt.expected.Metrics)
+			}
+			if config.ZoneSpread != tt.expected.ZoneSpread {
+				t.Errorf("ZoneSpread = %v, want %v", config.ZoneSpread, tt.expected.ZoneSpread)
+			}
+			if config.FromDockerfile != tt.expected.FromDockerfile {
+				t.Errorf("FromDockerfile = %v, want %v", config.FromDockerfile, tt.expected.FromDockerfile)
+			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestParseFlagsFromArgs_sidecar(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    []app.Sidecar
+		errContains string
+	}{
+		{
+			name:     "image only",
+			args:     []string{"-sidecar", "proxy=envoyproxy/envoy:v1.30"},
+			expected: []app.Sidecar{{Name: "proxy", Image: "envoyproxy/envoy:v1.30"}},
+		},
+		{
+			name: "port and mount",
+			args: []string{"-sidecar", "log-shipper=fluent/fluent-bit:3.0,port=2020,mount=/var/log/app/"},
+			expected: []app.Sidecar{
+				{Name: "log-shipper", Image: "fluent/fluent-bit:3.0", Port: 2020, MountPath: "/var/log/app"},
+			},
+		},
+		{
+			name: "repeated flag",
+			args: []string{"-sidecar", "a=busybox", "-sidecar", "b=busybox,port=8080"},
+			expected: []app.Sidecar{
+				{Name: "a", Image: "busybox"},
+				{Name: "b", Image: "busybox", Port: 8080},
+			},
+		},
+		{
+			name:        "missing image",
+			args:        []string{"-sidecar", "proxy"},
+			errContains: "expected name=image",
+		},
+		{
+			name:        "invalid name",
+			args:        []string{"-sidecar", "Proxy=envoy"},
+			errContains: "sidecar name must contain only lowercase letters",
+		},
+		{
+			name:        "invalid port",
+			args:        []string{"-sidecar", "proxy=envoy,port=70000"},
+			errContains: "invalid port",
+		},
+		{
+			name:        "relative mount path",
+			args:        []string{"-sidecar", "proxy=envoy,mount=logs"},
+			errContains: "must be absolute",
+		},
+		{
+			name:        "unknown option",
+			args:        []string{"-sidecar", "proxy=envoy,cpu=1"},
+			errContains: "unknown option",
+		},
+		{
+			name:        "reserved volume name",
+			args:        []string{"-sidecar", "data=busybox,mount=/data"},
+			errContains: "reserved for a volume of the chart",
+		},
+		{
+			name:        "duplicate name",
+			args:        []string{"-sidecar", "proxy=envoy", "-sidecar", "proxy=nginx"},
+			errContains: "declared more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseFlagsFromArgs(tt.args)
+			if tt.errContains != "" {
+				if err == nil {
+					t.Fatalf("ParseFlagsFromArgs() expected error containing %q", tt.errContains)
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("ParseFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(config.Sidecars, tt.expected) {
+				t.Errorf("Sidecars = %+v, want %+v", config.Sidecars, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseFlagsFromArgs_port(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    []app.Port
+		errContains string
+	}{
+		{
+			name:     "single port",
+			args:     []string{"-port", "http:8080"},
+			expected: []app.Port{{Name: "http", Number: 8080}},
+		},
+		{
+			name:     "repeated flag",
+			args:     []string{"-port", "http:8080", "-port", "grpc:9000"},
+			expected: []app.Port{{Name: "http", Number: 8080}, {Name: "grpc", Number: 9000}},
+		},
+		{
+			name:        "missing number",
+			args:        []string{"-port", "http"},
+			errContains: "expected name:number",
+		},
+		{
+			name:        "name too long",
+			args:        []string{"-port", "a-very-long-port-name:8080"},
+			errContains: "port name must be at most 15",
+		},
+		{
+			name:        "numeric name",
+			args:        []string{"-port", "8080:8080"},
+			errContains: "port name must be at most 15",
+		},
+		{
+			name:        "invalid number",
+			args:        []string{"-port", "http:0"},
+			errContains: "invalid port",
+		},
+		{
+			name:        "duplicate name",
+			args:        []string{"-port", "http:8080", "-port", "http:8081"},
+			errContains: "conflicts with http:8080",
+		},
+		{
+			name:        "duplicate number",
+			args:        []string{"-port", "http:8080", "-port", "web:8080"},
+			errContains: "conflicts with http:8080",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseFlagsFromArgs(tt.args)
+			if tt.errContains != "" {
+				if err == nil {
+					t.Fatalf("ParseFlagsFromArgs() expected error containing %q", tt.errContains)
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("ParseFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(config.Ports, tt.expected) {
+				t.Errorf("Ports = %+v, want %+v", config.Ports, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseLintFlagsFromArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    LintConfig
+		errContains string
+	}{
+		{
+			name:     "chart only",
+			args:     []string{"mychart"},
+			expected: LintConfig{ChartDir: "mychart"},
+		},
+		{
+			name:     "explicit profile",
+			args:     []string{"-security-profile", "restricted", "mychart"},
+			expected: LintConfig{ChartDir: "mychart", SecurityProfile: "restricted"},
+		},
+		{
+			name:        "missing chart",
+			args:        []string{},
+			errContains: "chart directory is required",
+		},
+		{
+			name:        "several charts",
+			args:        []string{"a", "b"},
+			errContains: "only one chart directory is allowed",
+		},
+		{
+			name:        "invalid profile",
+			args:        []string{"-security-profile", "privileged", "mychart"},
+			errContains: "security profile must be one of",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseLintFlagsFromArgs(tt.args)
+			if err == nil {
+				err = config.Validate()
+			}
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseLintFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLintFlagsFromArgs() error = %v", err)
+			}
+			if *config != tt.expected {
+				t.Errorf("config = %+v, want %+v", *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseImportFlagsFromArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    ImportConfig
+		errContains string
+	}{
+		{
+			name:     "sources",
+			args:     []string{"-n", "web", "-o", "out", "web.yaml", "manifests"},
+			expected: ImportConfig{ChartName: "web", OutputDir: "out", Sources: []string{"web.yaml", "manifests"}},
+		},
+		{
+			name:     "stdin by default",
+			args:     []string{"-n", "web", "-o", "out"},
+			expected: ImportConfig{ChartName: "web", OutputDir: "out", Sources: []string{"-"}},
+		},
+		{
+			name:        "missing chart path",
+			args:        []string{"-n", "web", "web.yaml"},
+			errContains: "chart path is required",
+		},
+		{
+			name:        "invalid chart name",
+			args:        []string{"-n", "Web", "-o", "out"},
+			errContains: "chart name must start with a lowercase letter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseImportFlagsFromArgs(tt.args)
+			if err == nil {
+				err = config.Validate()
+			}
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseImportFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseImportFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(*config, tt.expected) {
+				t.Errorf("config = %+v, want %+v", *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseComposeFlagsFromArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    ComposeConfig
+		errContains string
+	}{
+		{
+			name:     "services",
+			args:     []string{"-f", "stack/compose.yaml", "-n", "shop", "-o", "out", "-service", "web,api", "-service", "db"},
+			expected: ComposeConfig{ComposeFile: "stack/compose.yaml", ChartName: "shop", OutputDir: "out", Services: []string{"web", "api", "db"}},
+		},
+		{
+			name:     "default compose file",
+			args:     []string{"-n", "shop", "-o", "out"},
+			expected: ComposeConfig{ChartName: "shop", OutputDir: "out"},
+		},
+		{
+			name:        "positional argument",
+			args:        []string{"-n", "shop", "-o", "out", "compose.yaml"},
+			errContains: "unexpected argument compose.yaml",
+		},
+		{
+			name:        "missing chart path",
+			args:        []string{"-n", "shop"},
+			errContains: "chart path is required",
+		},
+		{
+			name:        "missing chart name",
+			args:        []string{"-o", "out"},
+			errContains: "chart name is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseComposeFlagsFromArgs(tt.args)
+			if err == nil {
+				err = config.Validate()
+			}
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseComposeFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseComposeFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(*config, tt.expected) {
+				t.Errorf("config = %+v, want %+v", *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseKustomizeFlagsFromArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    KustomizeConfig
+		errContains string
+	}{
+		{
+			name:     "kustomization directory",
+			args:     []string{"-n", "web", "-o", "out", "overlays/prod"},
+			expected: KustomizeConfig{Dir: "overlays/prod", ChartName: "web", OutputDir: "out"},
+		},
+		{
+			name:     "current directory",
+			args:     []string{"-n", "web", "-o", "out"},
+			expected: KustomizeConfig{Dir: ".", ChartName: "web", OutputDir: "out"},
+		},
+		{
+			name:        "several directories",
+			args:        []string{"-n", "web", "-o", "out", "base", "overlays/prod"},
+			errContains: "unexpected argument overlays/prod",
+		},
+		{
+			name:        "missing chart path",
+			args:        []string{"-n", "web"},
+			errContains: "chart path is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseKustomizeFlagsFromArgs(tt.args)
+			if err == nil {
+				err = config.Validate()
+			}
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseKustomizeFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKustomizeFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(*config, tt.expected) {
+				t.Errorf("config = %+v, want %+v", *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseUpgradeFlagsFromArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    UpgradeConfig
+		errContains string
+	}{
+		{
+			name:     "chart",
+			args:     []string{"charts/web"},
+			expected: UpgradeConfig{ChartDir: "charts/web"},
+		},
+		{
+			name:     "generator of the recorded version",
+			args:     []string{"-generator", "/tmp/helmchart-helper-v1.0.0", "charts/web"},
+			expected: UpgradeConfig{ChartDir: "charts/web", Generator: "/tmp/helmchart-helper-v1.0.0"},
+		},
+		{
+			name:        "missing chart",
+			args:        []string{},
+			errContains: "chart directory is required",
+		},
+		{
+			name:        "several charts",
+			args:        []string{"web", "api"},
+			errContains: "unexpected argument api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseUpgradeFlagsFromArgs(tt.args)
+			if err == nil {
+				err = config.Validate()
+			}
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseUpgradeFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseUpgradeFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(*config, tt.expected) {
+				t.Errorf("config = %+v, want %+v", *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseUmbrellaFlagsFromArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    map[string][]bool
+		errContains string
+	}{
+		{
+			name: "two components",
+			args: []string{"-n", "product", "-o", "/tmp/product",
+				"-component", "api=deploy,svc,probe-type=tcp", "-component", "worker=cj"},
+			// deployment, service, cronjob
+			expected: map[string][]bool{
+				"api":    {true, true, false},
+				"worker": {false, false, true},
+			},
+		},
+		{
+			name:        "no component",
+			args:        []string{"-n", "product", "-o", "/tmp/product"},
+			errContains: "at least one component is required",
+		},
+		{
+			name:        "invalid component name",
+			args:        []string{"-n", "product", "-o", "/tmp/product", "-component", "Api=deploy"},
+			errContains: "invalid component name",
+		},
+		{
+			name:        "duplicate component",
+			args:        []string{"-n", "product", "-o", "/tmp/product", "-component", "api=deploy", "-component", "api=sts"},
+			errContains: `component "api" is declared more than once`,
+		},
+		{
+			name:        "unknown option",
+			args:        []string{"-n", "product", "-o", "/tmp/product", "-component", "api=deploy,bogus"},
+			errContains: "flag provided but not defined",
+		},
+		{
+			name:        "output set by the component",
+			args:        []string{"-n", "product", "-o", "/tmp/product", "-component", "api=deploy,o=/tmp"},
+			errContains: "is set by the umbrella command",
+		},
+		{
+			name:        "invalid component resources",
+			args:        []string{"-n", "product", "-o", "/tmp/product", "-component", "api=cert"},
+			errContains: "cert requires an ingress",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseUmbrellaFlagsFromArgs(tt.args)
+			if err == nil {
+				err = config.Validate()
+			}
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseUmbrellaFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseUmbrellaFlagsFromArgs() error = %v", err)
+			}
+			if len(config.Components) != len(tt.expected) {
+				t.Fatalf("Components = %d, want %d", len(config.Components), len(tt.expected))
+			}
+			for _, c := range config.Components {
+				got := []bool{c.Config.Deployment, c.Config.Service, c.Config.Cronjob}
+				if !reflect.DeepEqual(got, tt.expected[c.Name]) {
+					t.Errorf("component %s resources = %v, want %v", c.Name, got, tt.expected[c.Name])
+				}
+				if c.Config.OutputDir != "/tmp/product/charts/"+c.Name {
+					t.Errorf("component %s OutputDir = %q", c.Name, c.Config.OutputDir)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFlagsFromArgs_dependency(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    []app.Dependency
+		errContains string
+	}{
+		{
+			name: "default condition",
+			args: []string{"-dependency", "postgresql=oci://registry-1.docker.io/bitnamicharts@15.5.0"},
+			expected: []app.Dependency{
+				{Name: "postgresql", Repository: "oci://registry-1.docker.io/bitnamicharts", Version: "15.5.0", Condition: "postgresql.enabled"},
+			},
+		},
+		{
+			name: "custom condition",
+			args: []string{"-dependency", "redis=https://charts.bitnami.com/bitnami@19.x.x,condition=cache.redis.enabled"},
+			expected: []app.Dependency{
+				{Name: "redis", Repository: "https://charts.bitnami.com/bitnami", Version: "19.x.x", Condition: "cache.redis.enabled"},
+			},
+		},
+		{
+			name:        "missing version",
+			args:        []string{"-dependency", "redis=https://charts.bitnami.com/bitnami"},
+			errContains: "expected name=repository@version",
+		},
+		{
+			name:        "invalid repository",
+			args:        []string{"-dependency", "redis=bitnami@19.0.0"},
+			errContains: "repository must start with",
+		},
+		{
+			name:        "invalid condition",
+			args:        []string{"-dependency", "redis=https://charts@19.0.0,condition=cache..enabled"},
+			errContains: "invalid condition",
+		},
+		{
+			name:        "unknown option",
+			args:        []string{"-dependency", "redis=https://charts@19.0.0,alias=cache"},
+			errContains: `unknown option "alias"`,
+		},
+		{
+			name:        "duplicate dependency",
+			args:        []string{"-dependency", "redis=https://charts@19.0.0", "-dependency", "redis=https://charts@20.0.0"},
+			errContains: `dependency "redis" is declared more than once`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseFlagsFromArgs(tt.args)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ParseFlagsFromArgs() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFlagsFromArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(config.Dependencies, tt.expected) {
+				t.Errorf("Dependencies = %+v, want %+v", config.Dependencies, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseFlagsFromArgs_library(t *testing.T) {
+	config, err := ParseFlagsFromArgs([]string{"-library", "lib=oci://registry/charts@1.2.0", "-deploy"})
+	if err != nil {
+		t.Fatalf("ParseFlagsFromArgs() error = %v", err)
+	}
+	want := app.Dependency{Name: "lib", Repository: "oci://registry/charts", Version: "1.2.0"}
+	if config.Library != want {
+		t.Errorf("Library = %+v, want %+v", config.Library, want)
+	}
+	if config.ChartType != app.ChartTypeApplication {
+		t.Errorf("ChartType = %q, want %q", config.ChartType, app.ChartTypeApplication)
+	}
+
+	if _, err := ParseFlagsFromArgs([]string{"-library", "lib=oci://registry/charts@1.2.0,condition=lib.enabled"}); err == nil ||
+		!strings.Contains(err.Error(), "expected name=repository@version") {
+		t.Errorf("ParseFlagsFromArgs() error = %v, want error containing %q", err, "expected name=repository@version")
+	}
+}
+
+func TestParseFlagsFromArgs_env(t *testing.T) {
+	config, err := ParseFlagsFromArgs([]string{"-env", "dev, staging", "-env", "prod", "-env-profile", "envs.yaml", "-deploy"})
+	if err != nil {
+		t.Fatalf("ParseFlagsFromArgs() error = %v", err)
+	}
+	if want := []string{"dev", "staging", "prod"}; !reflect.DeepEqual(config.Environments, want) {
+		t.Errorf("Environments = %v, want %v", config.Environments, want)
+	}
+	if config.EnvProfile != "envs.yaml" {
+		t.Errorf("EnvProfile = %q, want %q", config.EnvProfile, "envs.yaml")
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		errContains string
+	}{
+		{name: "valid", args: []string{"-env", "dev,qa-1"}},
+		{name: "invalid name", args: []string{"-env", "Prod"}, errContains: "environment must contain only lowercase letters"},
+		{name: "duplicate", args: []string{"-env", "dev,prod", "-env", "dev"}, errContains: `environment "dev" is declared more than once`},
+		{name: "profile without environments", args: []string{"-env-profile", "envs.yaml"}, errContains: "environment profile requires environments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseFlagsFromArgs(append([]string{"-n", "test", "-o", "out", "-deploy"}, tt.args...))
+			if err != nil {
+				t.Fatalf("ParseFlagsFromArgs() error = %v", err)
+			}
+			err = config.Validate()
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Validate() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
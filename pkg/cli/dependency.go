@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// repositoryPrefixes are the schemes of the chart repositories.
+var repositoryPrefixes = []string{"https://", "http://", "oci://", "file://"}
+
+// conditionRegexp validates the values path of a dependency condition.
+var conditionRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// dependencyFlag implements flag.Value for the repeatable -dependency flag.
+// Each occurrence has the form name=repository@version[,condition=path].
+type dependencyFlag struct {
+	dependencies *[]app.Dependency
+}
+
+// String returns the dependencies as they would be written on the command line.
+func (f dependencyFlag) String() string {
+	if f.dependencies == nil {
+		return ""
+	}
+	specs := make([]string, 0, len(*f.dependencies))
+	for _, d := range *f.dependencies {
+		specs = append(specs, d.Name+"="+d.Repository+"@"+d.Version+",condition="+d.Condition)
+	}
+	return strings.Join(specs, " ")
+}
+
+// Set parses one -dependency occurrence and appends it to the list.
+func (f dependencyFlag) Set(value string) error {
+	dependency, err := parseDependency(value)
+	if err != nil {
+		return err
+	}
+	for _, d := range *f.dependencies {
+		if d.Name == dependency.Name {
+			return dependencyError(value, fmt.Sprintf("dependency %q is declared more than once", dependency.Name))
+		}
+	}
+	*f.dependencies = append(*f.dependencies, dependency)
+	return nil
+}
+
+// parseDependency parses a name=repository@version[,condition=path]
+// specification.
+func parseDependency(value string) (app.Dependency, error) {
+	var dependency app.Dependency
+	parts := strings.Split(value, ",")
+
+	name, source, _ := strings.Cut(parts[0], "=")
+	at := strings.LastIndex(source, "@")
+	if name == "" || at <= 0 || at == len(source)-1 {
+		return dependency, dependencyError(value, "expected name=repository@version[,condition=path]")
+	}
+	if err := validateChartName(name); err != nil {
+		return dependency, dependencyError(value, "invalid dependency name: "+err.Error())
+	}
+	dependency.Name = name
+	dependency.Repository = source[:at]
+	dependency.Version = source[at+1:]
+	dependency.Condition = name + ".enabled"
+
+	validRepository := false
+	for _, prefix := range repositoryPrefixes {
+		if strings.HasPrefix(dependency.Repository, prefix) && len(dependency.Repository) > len(prefix) {
+			validRepository = true
+		}
+	}
+	if !validRepository {
+		return dependency, dependencyError(value,
+			"repository must start with "+strings.Join(repositoryPrefixes, ", "))
+	}
+
+	for _, part := range parts[1:] {
+		key, val, _ := strings.Cut(part, "=")
+		switch key {
+		case "condition":
+			if !conditionRegexp.MatchString(val) {
+				return dependency, dependencyError(value, fmt.Sprintf("invalid condition %q", val))
+			}
+			dependency.Condition = val
+		default:
+			return dependency, dependencyError(value, fmt.Sprintf("unknown option %q", key))
+		}
+	}
+
+	return dependency, nil
+}
+
+// validateDependencyConditions checks that the values generated for the
+// dependencies do not overlap: a dependency name or condition cannot be a
+// values key of the chart, a condition cannot be the values block of a
+// dependency or a parent of another condition.
+func validateDependencyConditions(dependencies []app.Dependency) error {
+	keys := app.ValuesKeys()
+	for _, d := range dependencies {
+		root, _, _ := strings.Cut(d.Condition, ".")
+		for _, key := range []string{d.Name, root} {
+			if slices.Contains(keys, key) {
+				return errors.NewValidationError("validate-config",
+					fmt.Sprintf("dependency %q uses the values key %q of the chart", d.Name, key)).
+					WithContext("flag", "-dependency")
+			}
+		}
+		for _, other := range dependencies {
+			if d.Condition == other.Name || (d.Name != other.Name && d.Condition == other.Condition) ||
+				strings.HasPrefix(other.Condition, d.Condition+".") {
+				return errors.NewValidationError("validate-config",
+					fmt.Sprintf("condition %q of dependency %q overlaps the values of dependency %q", d.Condition, d.Name, other.Name)).
+					WithContext("flag", "-dependency")
+			}
+		}
+	}
+	return nil
+}
+
+func dependencyError(value, msg string) error {
+	return errors.NewValidationError("parse-dependency", msg).
+		WithContext("flag", "-dependency").
+		WithContext("value", value)
+}
+
+// libraryFlag implements flag.Value for the -library flag of the form
+// name=repository@version.
+type libraryFlag struct {
+	library *app.Dependency
+}
+
+// String returns the library as it would be written on the command line.
+func (f libraryFlag) String() string {
+	if f.library == nil || f.library.Name == "" {
+		return ""
+	}
+	return f.library.Name + "=" + f.library.Repository + "@" + f.library.Version
+}
+
+// Set parses the -library flag.
+func (f libraryFlag) Set(value string) error {
+	if strings.Contains(value, ",") {
+		return errors.NewValidationError("parse-library", "expected name=repository@version").
+			WithContext("flag", "-library").
+			WithContext("value", value)
+	}
+	library, err := parseDependency(value)
+	if err != nil {
+		return errors.WrapError(err, errors.ValidationError, "parse-library", "invalid library").
+			WithContext("flag", "-library")
+	}
+	library.Condition = ""
+	*f.library = library
+	return nil
+}
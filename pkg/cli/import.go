@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+)
+
+// ImportConfig holds the configuration of the import command:
+//
+//	helmchart-helper import -n name -o path [manifest|directory|-]...
+type ImportConfig struct {
+	ChartName string
+	OutputDir string
+	Sources   []string
+	Help      bool
+}
+
+// ParseImportFlagsFromArgs parses the arguments following the import command.
+func ParseImportFlagsFromArgs(args []string) (*ImportConfig, error) {
+	config := &ImportConfig{}
+	flagSet := flag.NewFlagSet("import", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.ChartName, "n", "", "Name of the chart")
+	flagSet.StringVar(&config.OutputDir, "o", "", "Path of the generated chart")
+	flagSet.BoolVar(&config.Help, "help", false, "Print help")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	config.Sources = flagSet.Args()
+
+	return config, nil
+}
+
+// Validate validates the import configuration, the manifests are read from the
+// standard input when no source is given.
+func (c *ImportConfig) Validate() error {
+	if err := validateChartName(c.ChartName); err != nil {
+		return err
+	}
+	if c.OutputDir == "" {
+		return errors.NewValidationError("validate-import", "chart path is required").
+			WithContext("flag", "-o")
+	}
+	if len(c.Sources) == 0 {
+		c.Sources = []string{importer.Stdin}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// KustomizeConfig holds the configuration of the import-kustomize command:
+//
+//	helmchart-helper import-kustomize -n name -o path [directory]
+type KustomizeConfig struct {
+	// Dir is the directory of the kustomization, the current directory by
+	// default.
+	Dir       string
+	ChartName string
+	OutputDir string
+	Help      bool
+}
+
+// ParseKustomizeFlagsFromArgs parses the arguments following the
+// import-kustomize command.
+func ParseKustomizeFlagsFromArgs(args []string) (*KustomizeConfig, error) {
+	config := &KustomizeConfig{}
+	flagSet := flag.NewFlagSet("import-kustomize", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.ChartName, "n", "", "Name of the chart")
+	flagSet.StringVar(&config.OutputDir, "o", "", "Path of the generated chart")
+	flagSet.BoolVar(&config.Help, "help", false, "Print help")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	switch flagSet.NArg() {
+	case 0:
+		config.Dir = "."
+	case 1:
+		config.Dir = flagSet.Arg(0)
+	default:
+		return nil, errors.NewValidationError("parse-kustomize-flags", "unexpected argument "+flagSet.Arg(1))
+	}
+
+	return config, nil
+}
+
+// Validate validates the import-kustomize configuration.
+func (c *KustomizeConfig) Validate() error {
+	if err := validateChartName(c.ChartName); err != nil {
+		return err
+	}
+	if c.OutputDir == "" {
+		return errors.NewValidationError("validate-kustomize", "chart path is required").
+			WithContext("flag", "-o")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// LintConfig holds the configuration of the lint command:
+//
+//	helmchart-helper lint [-security-profile profile] <chart>
+type LintConfig struct {
+	ChartDir        string
+	SecurityProfile string
+	Help            bool
+}
+
+// ParseLintFlagsFromArgs parses the arguments following the lint command.
+func ParseLintFlagsFromArgs(args []string) (*LintConfig, error) {
+	config := &LintConfig{}
+	flagSet := flag.NewFlagSet("lint", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.SecurityProfile, "security-profile", "", "pod security standard to check: restricted, baseline or none (default: the profile recorded in Chart.yaml)")
+	flagSet.BoolVar(&config.Help, "help", false, "Print help")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if flagSet.NArg() > 1 {
+		return nil, errors.NewValidationError("parse-lint", "only one chart directory is allowed").
+			WithContext("args", strings.Join(flagSet.Args(), " "))
+	}
+	config.ChartDir = flagSet.Arg(0)
+
+	return config, nil
+}
+
+// Validate validates the lint configuration.
+func (c *LintConfig) Validate() error {
+	if c.ChartDir == "" {
+		return errors.NewValidationError("validate-lint", "chart directory is required")
+	}
+	if c.SecurityProfile != "" && !slices.Contains(app.SecurityProfiles, c.SecurityProfile) {
+		return errors.NewValidationError("validate-lint",
+			"security profile must be one of "+strings.Join(app.SecurityProfiles, ", ")).
+			WithContext("flag", "-security-profile").
+			WithContext("value", c.SecurityProfile)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// portNameRegexp validates port names (IANA service name): lowercase letters,
+// numbers and non-consecutive hyphens, not starting or ending with a hyphen.
+var portNameRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// maxPortNameLength is the maximum length of a port name.
+const maxPortNameLength = 15
+
+// portFlag implements flag.Value for the repeatable -port flag.
+// Each occurrence has the form name:number.
+type portFlag struct {
+	ports *[]app.Port
+}
+
+// String returns the ports as they would be written on the command line.
+func (f portFlag) String() string {
+	if f.ports == nil {
+		return ""
+	}
+	specs := make([]string, 0, len(*f.ports))
+	for _, p := range *f.ports {
+		specs = append(specs, p.Name+":"+strconv.Itoa(p.Number))
+	}
+	return strings.Join(specs, " ")
+}
+
+// Set parses one -port occurrence and appends it to the list.
+func (f portFlag) Set(value string) error {
+	port, err := parsePort(value)
+	if err != nil {
+		return err
+	}
+	for _, p := range *f.ports {
+		if p.Name == port.Name || p.Number == port.Number {
+			return portError(value, fmt.Sprintf("port %q conflicts with %s:%d", value, p.Name, p.Number))
+		}
+	}
+	*f.ports = append(*f.ports, port)
+	return nil
+}
+
+// parsePort parses a name:number specification.
+func parsePort(value string) (app.Port, error) {
+	var port app.Port
+
+	name, number, ok := strings.Cut(value, ":")
+	if !ok || name == "" || number == "" {
+		return port, portError(value, "expected name:number")
+	}
+	if len(name) > maxPortNameLength || !portNameRegexp.MatchString(name) ||
+		!strings.ContainsAny(name, "abcdefghijklmnopqrstuvwxyz") {
+		return port, portError(value,
+			fmt.Sprintf("port name must be at most %d lowercase letters, numbers, and hyphens", maxPortNameLength))
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > maxPort {
+		return port, portError(value, fmt.Sprintf("invalid port %q", number))
+	}
+
+	port.Name = name
+	port.Number = n
+	return port, nil
+}
+
+func portError(value, msg string) error {
+	return errors.NewValidationError("parse-port", msg).
+		WithContext("flag", "-port").
+		WithContext("value", value)
+}
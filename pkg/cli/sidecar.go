@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// containerNameRegexp validates container names (RFC 1123 DNS label).
+var containerNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// maxContainerNameLength is the maximum length of a container name.
+const maxContainerNameLength = 63
+
+// reservedVolumeNames are the pod volumes generated by the chart, a sidecar
+// mount volume is named after the sidecar and must not collide with them.
+var reservedVolumeNames = []string{"tmp", "data", "config-files"}
+
+// maxPort is the highest valid TCP port.
+const maxPort = 65535
+
+// sidecarFlag implements flag.Value for the repeatable -sidecar flag.
+// Each occurrence has the form name=image[,port=N][,mount=/path].
+type sidecarFlag struct {
+	sidecars *[]app.Sidecar
+}
+
+// String returns the sidecars as they would be written on the command line.
+func (f sidecarFlag) String() string {
+	if f.sidecars == nil {
+		return ""
+	}
+	specs := make([]string, 0, len(*f.sidecars))
+	for _, s := range *f.sidecars {
+		spec := s.Name + "=" + s.Image
+		if s.Port != 0 {
+			spec += ",port=" + strconv.Itoa(s.Port)
+		}
+		if s.MountPath != "" {
+			spec += ",mount=" + s.MountPath
+		}
+		specs = append(specs, spec)
+	}
+	return strings.Join(specs, " ")
+}
+
+// Set parses one -sidecar occurrence and appends it to the list.
+func (f sidecarFlag) Set(value string) error {
+	sidecar, err := parseSidecar(value)
+	if err != nil {
+		return err
+	}
+	for _, s := range *f.sidecars {
+		if s.Name == sidecar.Name {
+			return errors.NewValidationError("parse-sidecar",
+				fmt.Sprintf("sidecar %q is declared more than once", sidecar.Name)).
+				WithContext("flag", "-sidecar")
+		}
+	}
+	*f.sidecars = append(*f.sidecars, sidecar)
+	return nil
+}
+
+// parseSidecar parses a name=image[,port=N][,mount=/path] specification.
+func parseSidecar(value string) (app.Sidecar, error) {
+	var sidecar app.Sidecar
+	parts := strings.Split(value, ",")
+
+	name, image, ok := strings.Cut(parts[0], "=")
+	if !ok || name == "" || image == "" {
+		return sidecar, sidecarError(value, "expected name=image[,port=N][,mount=/path]")
+	}
+	if len(name) > maxContainerNameLength || !containerNameRegexp.MatchString(name) {
+		return sidecar, sidecarError(value,
+			"sidecar name must contain only lowercase letters, numbers, and hyphens")
+	}
+	if slices.Contains(reservedVolumeNames, name) {
+		return sidecar, sidecarError(value,
+			fmt.Sprintf("sidecar name %q is reserved for a volume of the chart", name))
+	}
+	sidecar.Name = name
+	sidecar.Image = image
+
+	for _, part := range parts[1:] {
+		key, val, _ := strings.Cut(part, "=")
+		switch key {
+		case "port":
+			port, err := strconv.Atoi(val)
+			if err != nil || port < 1 || port > maxPort {
+				return sidecar, sidecarError(value, fmt.Sprintf("invalid port %q", val))
+			}
+			sidecar.Port = port
+		case "mount":
+			if !path.IsAbs(val) {
+				return sidecar, sidecarError(value, fmt.Sprintf("mount path %q must be absolute", val))
+			}
+			sidecar.MountPath = path.Clean(val)
+		default:
+			return sidecar, sidecarError(value, fmt.Sprintf("unknown option %q", key))
+		}
+	}
+
+	return sidecar, nil
+}
+
+func sidecarError(value, msg string) error {
+	return errors.NewValidationError("parse-sidecar", msg).
+		WithContext("flag", "-sidecar").
+		WithContext("value", value)
+}
@@ -0,0 +1,46 @@
+package cli
+
+import "strings"
+
+// stringsFlag implements flag.Value for repeatable string flags.
+type stringsFlag struct {
+	values *[]string
+}
+
+// String returns the values joined by spaces.
+func (f stringsFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, " ")
+}
+
+// Set appends one occurrence of the flag.
+func (f stringsFlag) Set(value string) error {
+	*f.values = append(*f.values, value)
+	return nil
+}
+
+// listFlag implements flag.Value for comma separated lists, the flag can also
+// be repeated.
+type listFlag struct {
+	values *[]string
+}
+
+// String returns the values joined by commas.
+func (f listFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, ",")
+}
+
+// Set appends the comma separated values of one occurrence of the flag.
+func (f listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f.values = append(*f.values, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// UmbrellaConfig holds the configuration of the umbrella command:
+//
+//	helmchart-helper umbrella -n name -o path -component name=deploy,svc ...
+type UmbrellaConfig struct {
+	ChartName  string
+	OutputDir  string
+	Components []Component
+	Help       bool
+}
+
+// Component is a component chart of an umbrella chart. Config holds the
+// resources selected for the component, as if they were given to the generator.
+type Component struct {
+	Name   string
+	Config *Config
+}
+
+// componentFlag implements flag.Value for the repeatable -component flag.
+// Each occurrence has the form name=option,option,... where the options are the
+// generator flags without the leading dash (deploy, svc, probe-type=tcp, ...).
+type componentFlag struct {
+	components *[]Component
+}
+
+// String returns the names of the components.
+func (f componentFlag) String() string {
+	if f.components == nil {
+		return ""
+	}
+	names := make([]string, 0, len(*f.components))
+	for _, c := range *f.components {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// Set parses one -component occurrence and appends it to the list.
+func (f componentFlag) Set(value string) error {
+	component, err := parseComponent(value)
+	if err != nil {
+		return err
+	}
+	for _, c := range *f.components {
+		if c.Name == component.Name {
+			return componentError(value, fmt.Sprintf("component %q is declared more than once", component.Name))
+		}
+	}
+	*f.components = append(*f.components, component)
+	return nil
+}
+
+// parseComponent parses a name=option,option,... specification.
+func parseComponent(value string) (Component, error) {
+	var component Component
+
+	name, spec, _ := strings.Cut(value, "=")
+	if err := validateChartName(name); err != nil {
+		return component, componentError(value, "invalid component name: "+err.Error())
+	}
+	args := []string{"-n", name}
+	for _, option := range strings.Split(spec, ",") {
+		if option == "" {
+			continue
+		}
+		key, val, hasValue := strings.Cut(option, "=")
+		if key == "n" || key == "o" {
+			return component, componentError(value, fmt.Sprintf("option %q is set by the umbrella command", key))
+		}
+		args = append(args, "-"+key)
+		if hasValue {
+			args = append(args, val)
+		}
+	}
+	config, err := ParseFlagsFromArgs(args)
+	if err != nil {
+		return component, componentError(value, err.Error())
+	}
+	if config.Version || config.Help {
+		return component, componentError(value, "version and help are not component options")
+	}
+
+	component.Name = name
+	component.Config = config
+	return component, nil
+}
+
+func componentError(value, msg string) error {
+	return errors.NewValidationError("parse-component", msg).
+		WithContext("flag", "-component").
+		WithContext("value", value)
+}
+
+// ParseUmbrellaFlagsFromArgs parses the arguments following the umbrella command.
+func ParseUmbrellaFlagsFromArgs(args []string) (*UmbrellaConfig, error) {
+	config := &UmbrellaConfig{}
+	flagSet := flag.NewFlagSet("umbrella", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.ChartName, "n", "", "Name of the umbrella chart")
+	flagSet.StringVar(&config.OutputDir, "o", "", "Path of the generated umbrella chart")
+	flagSet.Var(componentFlag{&config.Components}, "component", "component chart name=option,option,... with the generator flags as options, e.g. api=deploy,svc,ing (repeatable)")
+	flagSet.BoolVar(&config.Help, "help", false, "Print help")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	return config, nil
+}
+
+// Validate validates the umbrella configuration and the resources of every
+// component.
+func (c *UmbrellaConfig) Validate() error {
+	if err := validateChartName(c.ChartName); err != nil {
+		return err
+	}
+	if c.OutputDir == "" {
+		return errors.NewValidationError("validate-umbrella", "chart path is required").
+			WithContext("flag", "-o")
+	}
+	if len(c.Components) == 0 {
+		return errors.NewValidationError("validate-umbrella", "at least one component is required").
+			WithContext("flag", "-component")
+	}
+	for _, component := range c.Components {
+		component.Config.OutputDir = filepath.Join(c.OutputDir, "charts", component.Name)
+		if err := component.Config.Validate(); err != nil {
+			return errors.WrapError(err, errors.ValidationError, "validate-umbrella", "invalid component").
+				WithContext("component", component.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// UpgradeConfig holds the configuration of the upgrade command:
+//
+//	helmchart-helper upgrade [-generator path] chart
+type UpgradeConfig struct {
+	ChartDir string
+	// Generator is the binary of the version which generated the chart, it
+	// regenerates the base of the merge.
+	Generator string
+	Help      bool
+}
+
+// ParseUpgradeFlagsFromArgs parses the arguments following the upgrade
+// command.
+func ParseUpgradeFlagsFromArgs(args []string) (*UpgradeConfig, error) {
+	config := &UpgradeConfig{}
+	flagSet := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.Generator, "generator", "",
+		"helmchart-helper binary of the version recorded in the chart, required when it is not the current version")
+	flagSet.BoolVar(&config.Help, "help", false, "Print help")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if flagSet.NArg() > 1 {
+		return nil, errors.NewValidationError("parse-upgrade-flags", "unexpected argument "+flagSet.Arg(1))
+	}
+	config.ChartDir = flagSet.Arg(0)
+
+	return config, nil
+}
+
+// Validate validates the upgrade configuration.
+func (c *UpgradeConfig) Validate() error {
+	if c.ChartDir == "" {
+		return errors.NewValidationError("validate-upgrade", "chart directory is required")
+	}
+	return nil
+}
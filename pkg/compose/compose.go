@@ -0,0 +1,358 @@
+// Package compose converts the services of a docker-compose file into
+// Kubernetes objects, imported into a chart by pkg/importer.
+//
+// Conversion of a service:
+//   - Workload: a DaemonSet for deploy.mode global, a StatefulSet when a named
+//     volume is mounted (its first named volume is claimed by the
+//     volumeClaimTemplate), a Deployment otherwise
+//   - Ports: the ports and expose entries are container ports served by a
+//     Service, an Ingress routes to the published common HTTP ports
+//   - Environment: environment and env_file entries are loaded from a
+//     ConfigMap, the ones whose name looks sensitive (password, secret, token,
+//     key) from a Secret
+//   - Healthcheck: exec liveness and readiness probes
+//   - depends_on: an init container waits for the port of the dependency, the
+//     dependencies without port are listed in the notes of the chart
+//   - deploy.replicas and deploy.resources, user, privileged and cap_add
+//
+// The objects are named after the service, so that the services keep the
+// host names they have in the compose network. The variables of the file
+// (${VAR}, ${VAR:-default}, ...) are interpolated from the environment and
+// from the .env file next to it.
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
+)
+
+// DefaultFiles are the compose files looked up when no file is given.
+var DefaultFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Project is a parsed compose file.
+type Project struct {
+	// Services are the services in the order of the file.
+	Services []Service
+	// Volumes are the named volumes declared at the top level.
+	Volumes map[string]any
+	// dir is the directory of the compose file, the relative paths are
+	// resolved from it.
+	dir      string
+	warnings []string
+}
+
+// Service is a service of a compose file.
+type Service struct {
+	Name        string         `yaml:"-"`
+	Image       string         `yaml:"image"`
+	Build       any            `yaml:"build"`
+	Command     stringOrList   `yaml:"command"`
+	Entrypoint  stringOrList   `yaml:"entrypoint"`
+	Environment mappingOrList  `yaml:"environment"`
+	EnvFile     stringOrList   `yaml:"env_file"`
+	Ports       []any          `yaml:"ports"`
+	Expose      []any          `yaml:"expose"`
+	Volumes     []any          `yaml:"volumes"`
+	Tmpfs       stringOrList   `yaml:"tmpfs"`
+	Healthcheck *Healthcheck   `yaml:"healthcheck"`
+	DependsOn   dependsOn      `yaml:"depends_on"`
+	Deploy      *Deploy        `yaml:"deploy"`
+	User        string         `yaml:"user"`
+	Privileged  bool           `yaml:"privileged"`
+	CapAdd      []string       `yaml:"cap_add"`
+	Unsupported map[string]any `yaml:",inline"`
+}
+
+// Healthcheck is the healthcheck of a service.
+type Healthcheck struct {
+	Test        stringOrList `yaml:"test"`
+	Interval    string       `yaml:"interval"`
+	Timeout     string       `yaml:"timeout"`
+	Retries     int          `yaml:"retries"`
+	StartPeriod string       `yaml:"start_period"`
+	Disable     bool         `yaml:"disable"`
+	// shell reports whether the test is a string, run by the shell
+	shell bool
+}
+
+// Deploy is the deploy section of a service.
+type Deploy struct {
+	Mode      string `yaml:"mode"`
+	Replicas  *int   `yaml:"replicas"`
+	Resources struct {
+		Limits       Resources `yaml:"limits"`
+		Reservations Resources `yaml:"reservations"`
+	} `yaml:"resources"`
+}
+
+// Resources are the limits or the reservations of a service.
+type Resources struct {
+	Cpus   string `yaml:"cpus"`
+	Memory string `yaml:"memory"`
+}
+
+// stringOrList is a compose field written as a string or as a list.
+type stringOrList struct {
+	values []string
+	// isString reports whether the field is written as a string
+	isString bool
+}
+
+// UnmarshalYAML decodes a string or a list of strings.
+func (s *stringOrList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.isString = true
+		s.values = []string{node.Value}
+		return nil
+	}
+	return node.Decode(&s.values)
+}
+
+// mappingOrList is a compose field written as a map or as a list of
+// KEY=VALUE, a key without value is read from the environment.
+type mappingOrList struct {
+	keys   []string
+	values map[string]*string
+}
+
+// UnmarshalYAML decodes a map or a list of KEY=VALUE, keeping the order of
+// the keys.
+func (m *mappingOrList) UnmarshalYAML(node *yaml.Node) error {
+	m.values = map[string]*string{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, v := node.Content[i].Value, node.Content[i+1]
+			if v.Tag == "!!null" {
+				m.set(key, nil)
+				continue
+			}
+			value := v.Value
+			m.set(key, &value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			key, value, found := strings.Cut(item.Value, "=")
+			if !found {
+				m.set(key, nil)
+				continue
+			}
+			m.set(key, &value)
+		}
+	default:
+		return fmt.Errorf("line %d: expected a map or a list", node.Line)
+	}
+	return nil
+}
+
+func (m *mappingOrList) set(key string, value *string) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// dependsOn is the depends_on field, a list of services or a map of services
+// to their condition.
+type dependsOn []string
+
+// UnmarshalYAML decodes a list or a map of services.
+func (d *dependsOn) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			*d = append(*d, node.Content[i].Value)
+		}
+		return nil
+	}
+	return node.Decode((*[]string)(d))
+}
+
+// Loader reads compose files.
+type Loader struct {
+	fs interfaces.FileSystem
+	// lookupEnv reads the variables of the environment.
+	lookupEnv func(string) (string, bool)
+}
+
+// NewLoader creates a loader reading the compose files from fs, the variables
+// are interpolated from the environment of the process.
+func NewLoader(fs interfaces.FileSystem) *Loader {
+	return &Loader{fs: fs, lookupEnv: os.LookupEnv}
+}
+
+// Load reads and parses the compose file, the first of DefaultFiles found in
+// the current directory when path is empty.
+func (l *Loader) Load(path string) (*Project, error) {
+	var content []byte
+	var err error
+	if path == "" {
+		for _, name := range DefaultFiles {
+			if content, err = l.fs.ReadFile(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return nil, errors.NewValidationError("load-compose",
+				"no compose file found, expected one of "+strings.Join(DefaultFiles, ", "))
+		}
+	} else if content, err = l.fs.ReadFile(path); err != nil {
+		return nil, errors.NewFileSystemError("load-compose", "failed to read compose file", err).
+			WithFile(path)
+	}
+
+	project := &Project{dir: filepath.Dir(path)}
+	variables := l.variables(project.dir)
+	content = []byte(project.interpolate(string(content), variables))
+
+	var file struct {
+		Services yaml.Node      `yaml:"services"`
+		Volumes  map[string]any `yaml:"volumes"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, errors.NewValidationError("load-compose", "invalid compose file: "+err.Error()).
+			WithFile(path)
+	}
+	if file.Services.Kind != yaml.MappingNode || len(file.Services.Content) == 0 {
+		return nil, errors.NewValidationError("load-compose", "the compose file has no services").
+			WithFile(path)
+	}
+	project.Volumes = file.Volumes
+	for i := 0; i+1 < len(file.Services.Content); i += 2 {
+		service := Service{Name: file.Services.Content[i].Value}
+		if err := file.Services.Content[i+1].Decode(&service); err != nil {
+			return nil, errors.NewValidationError("load-compose", "invalid service: "+err.Error()).
+				WithFile(path).
+				WithContext("service", service.Name)
+		}
+		if service.Healthcheck != nil {
+			service.Healthcheck.shell = service.Healthcheck.Test.isString
+		}
+		if err := l.loadEnvFiles(project, &service); err != nil {
+			return nil, err
+		}
+		// a variable without value is passed from the environment
+		for key, value := range service.Environment.values {
+			if v, ok := variables(key); value == nil && ok {
+				service.Environment.values[key] = &v
+			}
+		}
+		project.Services = append(project.Services, service)
+	}
+	return project, nil
+}
+
+// loadEnvFiles adds the variables of the env_file entries to the environment
+// of the service, the environment entries take precedence.
+func (l *Loader) loadEnvFiles(project *Project, service *Service) error {
+	for _, file := range service.EnvFile.values {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(project.dir, file)
+		}
+		content, err := l.fs.ReadFile(path)
+		if err != nil {
+			return errors.NewFileSystemError("load-compose", "failed to read env_file", err).
+				WithFile(path).
+				WithContext("service", service.Name)
+		}
+		if service.Environment.values == nil {
+			service.Environment.values = map[string]*string{}
+		}
+		for _, kv := range parseEnvFile(string(content)) {
+			if _, ok := service.Environment.values[kv[0]]; !ok {
+				value := kv[1]
+				service.Environment.set(kv[0], &value)
+			}
+		}
+	}
+	return nil
+}
+
+// variables returns the lookup of the variables interpolated in the compose
+// file: the environment takes precedence over the .env file of dir.
+func (l *Loader) variables(dir string) func(string) (string, bool) {
+	dotEnv := map[string]string{}
+	if content, err := l.fs.ReadFile(filepath.Join(dir, ".env")); err == nil {
+		for _, kv := range parseEnvFile(string(content)) {
+			dotEnv[kv[0]] = kv[1]
+		}
+	}
+	return func(name string) (string, bool) {
+		if value, ok := l.lookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := dotEnv[name]
+		return value, ok
+	}
+}
+
+// parseEnvFile parses the KEY=VALUE lines of an env file, the comments and the
+// empty lines are ignored and the quotes around the values are removed.
+func parseEnvFile(content string) [][2]string {
+	var kvs [][2]string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		kvs = append(kvs, [2]string{strings.TrimSpace(key), value})
+	}
+	return kvs
+}
+
+// variable matches $$, ${VAR}, ${VAR:-default} (and the -, :?, ?, :+, +
+// operators) and $VAR.
+var variable = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?+])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// interpolate replaces the variables of the compose file.
+func (p *Project) interpolate(content string, lookupEnv func(string) (string, bool)) string {
+	return variable.ReplaceAllStringFunc(content, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		groups := variable.FindStringSubmatch(match)
+		name, operator, operand := groups[1], groups[2], groups[3]
+		if name == "" {
+			name = groups[4]
+		}
+		value, set := lookupEnv(name)
+		switch operator {
+		case ":-":
+			if value == "" {
+				return operand
+			}
+		case "-":
+			if !set {
+				return operand
+			}
+		case ":+":
+			if value != "" {
+				return operand
+			}
+			return ""
+		case "+":
+			if set {
+				return operand
+			}
+			return ""
+		}
+		if !set {
+			p.warnings = append(p.warnings, fmt.Sprintf("variable %s is not set, it is replaced by an empty string", name))
+		}
+		return value
+	})
+}
@@ -0,0 +1,343 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+	"github.com/sgaunet/helmchart-helper/pkg/mocks"
+)
+
+const stackCompose = `services:
+  web:
+    image: nginx:${NGINX_TAG:-1.27}
+    ports:
+      - "8080:80"
+    depends_on:
+      api:
+        condition: service_healthy
+    volumes:
+      - ./nginx.conf:/etc/nginx/nginx.conf:ro
+  api:
+    build: ./api
+    entrypoint: /app/server
+    command: --listen ":3000" --verbose
+    expose:
+      - "3000"
+    env_file: api.env
+    environment:
+      - DB_HOST=db
+      - DB_PASSWORD=${DB_PASSWORD}
+      - LOG_LEVEL=info
+    healthcheck:
+      test: ["CMD", "wget", "-qO-", "http://localhost:3000/health"]
+      interval: 10s
+      timeout: 5s
+      retries: 5
+      start_period: 1m
+    deploy:
+      replicas: 2
+      resources:
+        limits: {cpus: "0.5", memory: 512M}
+        reservations: {cpus: "2", memory: 1gb}
+    depends_on: [db, worker]
+    user: "1000:1000"
+  db:
+    image: postgres:16
+    environment:
+      POSTGRES_PASSWORD: example
+    expose: [5432]
+    volumes:
+      - db-data:/var/lib/postgresql/data
+      - cache:/cache
+    tmpfs: /run
+    healthcheck:
+      test: pg_isready -U postgres
+  worker:
+    image: busybox
+    command: ["sleep", "infinity"]
+    working_dir: /app
+    restart: always
+  agent:
+    image: datadog/agent
+    deploy:
+      mode: global
+volumes:
+  db-data:
+  cache:
+`
+
+// get returns the value at the path of nested maps and lists (the index of
+// the list element).
+func get(v any, path ...any) any {
+	for _, key := range path {
+		switch k := key.(type) {
+		case string:
+			m, _ := v.(map[string]any)
+			if o, ok := v.(importer.Object); ok {
+				m = o
+			}
+			v = m[k]
+		case int:
+			l, _ := v.([]any)
+			if k >= len(l) {
+				return nil
+			}
+			v = l[k]
+		}
+	}
+	return v
+}
+
+func newLoader(files map[string]string, env map[string]string) *Loader {
+	fs := mocks.NewMockFileSystem()
+	for name, content := range files {
+		_ = fs.WriteFile(name, []byte(content), 0644)
+	}
+	return &Loader{fs: fs, lookupEnv: func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}}
+}
+
+func TestLoader_Load(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		env         map[string]string
+		path        string
+		image       string
+		environment map[string]string
+		warnings    []string
+		errContains string
+	}{
+		{
+			name: "interpolation from .env",
+			files: map[string]string{
+				"app/compose.yaml": "services:\n  web:\n    image: nginx:${TAG:-latest}\n    environment:\n      PASSWORD: $${SECRET}\n      USER: ${USER}\n",
+				"app/.env":         "# tags\nTAG=\"1.27\"\nUSER=admin\n",
+			},
+			env:         map[string]string{"USER": "root"},
+			path:        "app/compose.yaml",
+			image:       "nginx:1.27",
+			environment: map[string]string{"PASSWORD": "${SECRET}", "USER": "root"},
+		},
+		{
+			name:        "default value and unset variable",
+			files:       map[string]string{"compose.yaml": "services:\n  web:\n    image: nginx:${TAG:-1.27}\n    environment:\n      - HOST=${HOST}\n"},
+			image:       "nginx:1.27",
+			environment: map[string]string{"HOST": ""},
+			warnings:    []string{"variable HOST is not set, it is replaced by an empty string"},
+		},
+		{
+			name: "env_file and passed variables",
+			files: map[string]string{
+				"docker-compose.yml": "services:\n  web:\n    image: nginx\n    env_file: [web.env]\n    environment:\n      - MODE=prod\n      - TOKEN\n",
+				"web.env":            "MODE=dev\nexport LEVEL='debug'\n",
+			},
+			env:         map[string]string{"TOKEN": "t0k3n"},
+			image:       "nginx",
+			environment: map[string]string{"MODE": "prod", "TOKEN": "t0k3n", "LEVEL": "debug"},
+		},
+		{
+			name:        "missing env_file",
+			files:       map[string]string{"compose.yaml": "services:\n  web:\n    image: nginx\n    env_file: web.env\n"},
+			errContains: "failed to read env_file",
+		},
+		{
+			name:        "no compose file",
+			errContains: "no compose file found",
+		},
+		{
+			name:        "missing file",
+			path:        "other.yml",
+			errContains: "failed to read compose file",
+		},
+		{
+			name:        "no services",
+			files:       map[string]string{"compose.yaml": "volumes:\n  data:\n"},
+			errContains: "the compose file has no services",
+		},
+		{
+			name:        "invalid service",
+			files:       map[string]string{"compose.yaml": "services:\n  web:\n    environment: 3\n"},
+			errContains: "invalid service",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, err := newLoader(tt.files, tt.env).Load(tt.path)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Load() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			service := project.Services[0]
+			if service.Image != tt.image {
+				t.Errorf("image = %q, want %q", service.Image, tt.image)
+			}
+			environment := map[string]string{}
+			for key, value := range service.Environment.values {
+				if value != nil {
+					environment[key] = *value
+				}
+			}
+			if !reflect.DeepEqual(environment, tt.environment) {
+				t.Errorf("environment = %v, want %v", environment, tt.environment)
+			}
+			if !reflect.DeepEqual(project.warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", project.warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestProject_Convert(t *testing.T) {
+	loader := newLoader(map[string]string{
+		"compose.yaml": stackCompose,
+		".env":         "DB_PASSWORD=s3cret\n",
+		"api.env":      "LOG_LEVEL=debug\nNODE_ENV=production\n",
+	}, nil)
+	project, err := loader.Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	charts, warnings, err := project.Convert(nil)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	var names []string
+	objects := map[string]importer.Object{}
+	for _, chart := range charts {
+		names = append(names, chart.Name)
+		for _, object := range chart.Objects {
+			objects[object.String()] = object
+		}
+	}
+	if want := []string{"web", "api", "db", "worker", "agent"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("charts = %v, want %v", names, want)
+	}
+	wantNotes := []string{"api depends on worker, which declares no port: expose its ports in docker-compose to generate its Service"}
+	if !reflect.DeepEqual(charts[1].Notes, wantNotes) {
+		t.Errorf("notes of api = %q, want %q", charts[1].Notes, wantNotes)
+	}
+	wantWarnings := []string{
+		"web: bind mount ./nginx.conf:/etc/nginx/nginx.conf is not converted",
+		"api: is built from sources, push its image and set image.repository",
+		"worker: working_dir is not converted",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	tests := []struct {
+		name   string
+		object string
+		path   []any
+		want   any
+	}{
+		{"image with default tag", "Deployment/web", []any{"spec", "template", "spec", "containers", 0, "image"}, "nginx:1.27"},
+		{"service of the published port", "Service/web", []any{"spec", "ports", 0, "port"}, 80},
+		{"ingress of the http port", "Ingress/web", []any{"spec", "rules", 0, "host"}, "web.local"},
+		{"init container waiting for the dependency", "Deployment/web", []any{"spec", "template", "spec", "initContainers", 0, "command", 2},
+			"until nc -z api 3000; do echo waiting for api; sleep 2; done"},
+		{"init container waiting for an exposed port", "Deployment/api", []any{"spec", "template", "spec", "initContainers", 0, "name"}, "wait-for-db"},
+		{"image of a built service", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "image"}, "api"},
+		{"entrypoint", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "command"}, []any{"/app/server"}},
+		{"command split as a shell", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "args"},
+			[]any{"--listen", ":3000", "--verbose"}},
+		{"replicas", "Deployment/api", []any{"spec", "replicas"}, 2},
+		{"exposed port", "Service/api", []any{"spec", "ports", 0, "targetPort"}, 3000},
+		{"no ingress without published port", "Ingress/api", nil, nil},
+		{"configmap with env_file", "ConfigMap/api", []any{"data"},
+			map[string]any{"DB_HOST": "db", "LOG_LEVEL": "info", "NODE_ENV": "production"}},
+		{"sensitive variable from the secret", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "env", 0},
+			map[string]any{"name": "DB_PASSWORD", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "api-env", "key": "DB_PASSWORD"}}}},
+		{"secret data", "Secret/api-env", []any{"stringData"}, map[string]any{"DB_PASSWORD": "s3cret"}},
+		{"exec probe", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "livenessProbe"}, map[string]any{
+			"exec":          map[string]any{"command": []any{"wget", "-qO-", "http://localhost:3000/health"}},
+			"periodSeconds": 10, "timeoutSeconds": 5, "failureThreshold": 5, "initialDelaySeconds": 60,
+		}},
+		{"resources", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "resources"}, map[string]any{
+			"limits":   map[string]any{"cpu": "500m", "memory": "512Mi"},
+			"requests": map[string]any{"cpu": "2", "memory": "1Gi"},
+		}},
+		{"user", "Deployment/api", []any{"spec", "template", "spec", "containers", 0, "securityContext"},
+			map[string]any{"runAsUser": 1000, "runAsGroup": 1000}},
+		{"statefulset of a named volume", "StatefulSet/db", []any{"spec", "volumeClaimTemplates", 0, "metadata", "name"}, "db-data"},
+		{"shell healthcheck", "StatefulSet/db", []any{"spec", "template", "spec", "containers", 0, "readinessProbe", "exec", "command"},
+			[]any{"/bin/sh", "-c", "pg_isready -U postgres"}},
+		{"claim of each named volume", "StatefulSet/db", []any{"spec", "volumeClaimTemplates", 1, "metadata", "name"}, "cache"},
+		{"tmpfs", "StatefulSet/db", []any{"spec", "template", "spec", "volumes"}, []any{
+			map[string]any{"name": "tmpfs-2", "emptyDir": map[string]any{"medium": "Memory"}},
+		}},
+		{"no service without port", "Service/worker", nil, nil},
+		{"daemonset of a global service", "DaemonSet/agent", []any{"metadata", "name"}, "agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, ok := objects[tt.object]
+			if tt.path == nil {
+				if ok {
+					t.Errorf("unexpected object %s", tt.object)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("missing object %s", tt.object)
+			}
+			if got := get(object, tt.path...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s %v = %v, want %v", tt.object, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProject_Convert_services(t *testing.T) {
+	project, err := newLoader(map[string]string{"compose.yaml": stackCompose, "api.env": ""}, nil).Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	charts, _, err := project.Convert([]string{"worker"})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(charts) != 1 || charts[0].Name != "worker" {
+		t.Errorf("Convert() charts = %v, want the worker chart", charts)
+	}
+	if _, _, err := project.Convert([]string{"cache"}); err == nil || !strings.Contains(err.Error(), "unknown service cache") {
+		t.Errorf("Convert() error = %v, want unknown service", err)
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"cpu fraction", cpu("0.25"), "250m"},
+		{"cpu integer", cpu("1"), "1"},
+		{"memory megabytes", memory("256M"), "256Mi"},
+		{"memory gigabytes", memory("2gb"), "2Gi"},
+		{"memory bytes", memory("1048576"), "1048576"},
+		{"dns name", dnsName("My_Service.1"), "my-service-1"},
+		{"dns name starting with a digit", dnsName("1web"), "svc-1web"},
+		{"quoted command", stringOrList{values: []string{`sh -c 'echo "hello world"'`}, isString: true}.split(),
+			[]string{"sh", "-c", `echo "hello world"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,601 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+)
+
+// waitImage is the image of the init containers waiting for the dependencies.
+const waitImage = "busybox:1.36"
+
+// httpPorts are the common HTTP ports, an Ingress routes to the first of them
+// published by a service.
+var httpPorts = []int{80, 3000, 4200, 5000, 8000, 8080, 8081, 8888}
+
+// sensitiveEnv matches the names of the variables loaded from a Secret.
+var sensitiveEnv = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|credential|(^|_)(api_?|private_?)?key($|_))`)
+
+// ignoredKeys are the keys of a service without equivalent in a chart, which
+// do not change the behavior of the service.
+var ignoredKeys = []string{
+	"container_name", "restart", "networks", "labels", "logging", "platform",
+	"pull_policy", "profiles", "links", "hostname", "stdin_open", "tty", "stop_signal",
+}
+
+// service is a service being converted.
+type service struct {
+	Service
+	project *Project
+	// name is the name of the objects, a DNS label
+	name     string
+	ports    []port
+	objects  []importer.Object
+	notes    []string
+	warnings []string
+}
+
+// port is a port of a service, published when it is reachable from the host.
+type port struct {
+	number    int
+	protocol  string
+	published bool
+}
+
+// Convert converts the services of the project into charts of objects, all
+// the services when names is empty. It returns the charts in the order of the
+// compose file and the warnings about the parts of the services which are not
+// converted.
+func (p *Project) Convert(names []string) ([]importer.Chart, []string, error) {
+	for _, name := range names {
+		if p.service(name) == nil {
+			return nil, nil, errors.NewValidationError("convert-compose", "unknown service "+name).
+				WithContext("service", name)
+		}
+	}
+
+	warnings := slices.Clone(p.warnings)
+	var charts []importer.Chart
+	for _, svc := range p.Services {
+		if len(names) > 0 && !slices.Contains(names, svc.Name) {
+			continue
+		}
+		s := p.newService(svc)
+		s.convert()
+		charts = append(charts, importer.Chart{Name: s.name, Objects: s.objects, Notes: s.notes})
+		for _, w := range s.warnings {
+			warnings = append(warnings, svc.Name+": "+w)
+		}
+	}
+	return charts, warnings, nil
+}
+
+func (p *Project) service(name string) *Service {
+	for i := range p.Services {
+		if p.Services[i].Name == name {
+			return &p.Services[i]
+		}
+	}
+	return nil
+}
+
+func (p *Project) newService(svc Service) *service {
+	s := &service{Service: svc, project: p, name: dnsName(svc.Name)}
+	for _, spec := range svc.Ports {
+		s.ports = append(s.ports, s.parsePorts(spec, true)...)
+	}
+	for _, spec := range svc.Expose {
+		s.ports = append(s.ports, s.parsePorts(spec, false)...)
+	}
+	return s
+}
+
+// convert builds the objects of the service.
+func (s *service) convert() {
+	container := map[string]any{"name": s.name, "image": s.image()}
+	if len(s.Entrypoint.values) > 0 {
+		container["command"] = toList(s.Entrypoint.split())
+	}
+	if len(s.Command.values) > 0 {
+		container["args"] = toList(s.Command.split())
+	}
+	pod := map[string]any{"containers": []any{container}}
+
+	s.convertPorts(container)
+	s.convertEnvironment(container)
+	s.convertHealthcheck(container)
+	s.convertResources(container)
+	s.convertSecurity(container)
+	s.convertDependencies(pod)
+
+	workload := importer.Object{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": s.name},
+		"spec":       map[string]any{"template": map[string]any{"spec": pod}},
+	}
+	spec := workload["spec"].(map[string]any)
+	switch {
+	case s.Deploy != nil && s.Deploy.Mode == "global":
+		workload["kind"] = "DaemonSet"
+	case s.hasNamedVolume():
+		workload["kind"] = "StatefulSet"
+	}
+	if s.Deploy != nil && s.Deploy.Replicas != nil && workload.Kind() != "DaemonSet" {
+		spec["replicas"] = *s.Deploy.Replicas
+	}
+	s.convertVolumes(workload, container, pod)
+	s.objects = append([]importer.Object{workload}, s.objects...)
+	s.warnUnsupported()
+}
+
+// image returns the image of the service, a service only built from sources
+// is named after the service.
+func (s *service) image() string {
+	if s.Image != "" {
+		return s.Image
+	}
+	s.warnings = append(s.warnings, "is built from sources, push its image and set image.repository")
+	return s.name
+}
+
+// parsePorts parses a ports or an expose entry: [IP:][HOST:]CONTAINER[/PROTOCOL]
+// where CONTAINER may be a range, or the long syntax of ports.
+func (s *service) parsePorts(spec any, publishable bool) []port {
+	if long, ok := spec.(map[string]any); ok {
+		number, err := strconv.Atoi(fmt.Sprint(long["target"]))
+		if err != nil {
+			s.warnings = append(s.warnings, fmt.Sprintf("invalid port %v", long["target"]))
+			return nil
+		}
+		protocol, _ := long["protocol"].(string)
+		return []port{{number: number, protocol: protocol, published: long["published"] != nil}}
+	}
+
+	entry := fmt.Sprint(spec)
+	entry, protocol, _ := strings.Cut(entry, "/")
+	colon := strings.LastIndex(entry, ":")
+	published := publishable && colon >= 0
+	first, last, isRange := strings.Cut(entry[colon+1:], "-")
+	if !isRange {
+		last = first
+	}
+	from, err1 := strconv.Atoi(first)
+	to, err2 := strconv.Atoi(last)
+	if err1 != nil || err2 != nil || to < from {
+		s.warnings = append(s.warnings, "invalid port "+fmt.Sprint(spec))
+		return nil
+	}
+	var ports []port
+	for number := from; number <= to; number++ {
+		ports = append(ports, port{number: number, protocol: protocol, published: published})
+	}
+	return ports
+}
+
+// convertPorts declares the ports of the container, served by a Service. An
+// Ingress routes to the first published HTTP port.
+func (s *service) convertPorts(container map[string]any) {
+	var containerPorts, servicePorts []any
+	var ingressPort *port
+	for i, p := range s.ports {
+		if slices.ContainsFunc(s.ports[:i], func(o port) bool { return o.number == p.number && o.protocol == p.protocol }) {
+			continue
+		}
+		protocol := strings.ToUpper(p.protocol)
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		containerPorts = append(containerPorts, map[string]any{"containerPort": p.number, "protocol": protocol})
+		servicePorts = append(servicePorts, map[string]any{"port": p.number, "targetPort": p.number, "protocol": protocol})
+		if ingressPort == nil && p.published && protocol == "TCP" && slices.Contains(httpPorts, p.number) {
+			ingressPort = &s.ports[i]
+		}
+	}
+	if len(containerPorts) == 0 {
+		return
+	}
+	container["ports"] = containerPorts
+	s.objects = append(s.objects, importer.Object{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]any{"name": s.name},
+		"spec":       map[string]any{"type": "ClusterIP", "ports": servicePorts},
+	})
+	if ingressPort == nil {
+		return
+	}
+	s.objects = append(s.objects, importer.Object{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "Ingress",
+		"metadata":   map[string]any{"name": s.name},
+		"spec": map[string]any{
+			"rules": []any{map[string]any{
+				"host": s.name + ".local",
+				"http": map[string]any{"paths": []any{map[string]any{
+					"path":     "/",
+					"pathType": "Prefix",
+					"backend": map[string]any{"service": map[string]any{
+						"name": s.name,
+						"port": map[string]any{"number": ingressPort.number},
+					}},
+				}}},
+			}},
+		},
+	})
+}
+
+// convertEnvironment loads the variables from a ConfigMap named after the
+// service, the sensitive ones from the <service>-env Secret.
+func (s *service) convertEnvironment(container map[string]any) {
+	data, secretData := map[string]any{}, map[string]any{}
+	var env []any
+	for _, key := range s.Environment.keys {
+		value := s.Environment.values[key]
+		if value == nil {
+			s.warnings = append(s.warnings, fmt.Sprintf("variable %s is read from the environment, it is set to an empty string", key))
+			value = new(string)
+		}
+		if !sensitiveEnv.MatchString(key) {
+			data[key] = *value
+			continue
+		}
+		secretData[key] = *value
+		env = append(env, map[string]any{
+			"name": key,
+			"valueFrom": map[string]any{"secretKeyRef": map[string]any{
+				"name": s.name + "-env",
+				"key":  key,
+			}},
+		})
+	}
+	if len(data) > 0 {
+		container["envFrom"] = []any{map[string]any{"configMapRef": map[string]any{"name": s.name}}}
+		s.objects = append(s.objects, importer.Object{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": s.name},
+			"data":       data,
+		})
+	}
+	if len(env) > 0 {
+		container["env"] = env
+		s.objects = append(s.objects, importer.Object{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]any{"name": s.name + "-env"},
+			"type":       "Opaque",
+			"stringData": secretData,
+		})
+	}
+}
+
+// convertHealthcheck converts the healthcheck into exec liveness and
+// readiness probes.
+func (s *service) convertHealthcheck(container map[string]any) {
+	h := s.Healthcheck
+	if h == nil || h.Disable || len(h.Test.values) == 0 || h.Test.values[0] == "NONE" {
+		return
+	}
+	var command []string
+	switch {
+	case h.shell:
+		command = []string{"/bin/sh", "-c", h.Test.values[0]}
+	case h.Test.values[0] == "CMD":
+		command = h.Test.values[1:]
+	case h.Test.values[0] == "CMD-SHELL":
+		command = []string{"/bin/sh", "-c", strings.Join(h.Test.values[1:], " ")}
+	default:
+		s.warnings = append(s.warnings, "the healthcheck test must start with CMD or CMD-SHELL, it is not converted")
+		return
+	}
+
+	// the defaults of docker
+	probe := map[string]any{
+		"exec":             map[string]any{"command": toList(command)},
+		"periodSeconds":    s.seconds(h.Interval, 30),
+		"timeoutSeconds":   s.seconds(h.Timeout, 30),
+		"failureThreshold": 3,
+	}
+	if h.Retries > 0 {
+		probe["failureThreshold"] = h.Retries
+	}
+	readiness := make(map[string]any, len(probe))
+	for k, v := range probe {
+		readiness[k] = v
+	}
+	if h.StartPeriod != "" {
+		probe["initialDelaySeconds"] = s.seconds(h.StartPeriod, 0)
+	}
+	container["livenessProbe"] = probe
+	container["readinessProbe"] = readiness
+}
+
+// seconds converts a compose duration (1m30s) into seconds, at least 1.
+func (s *service) seconds(duration string, def int) int {
+	if duration == "" {
+		return def
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		s.warnings = append(s.warnings, fmt.Sprintf("invalid duration %q, %ds is used", duration, def))
+		return def
+	}
+	return max(1, int((d+time.Second-1)/time.Second))
+}
+
+// convertResources converts the limits and the reservations of the service.
+func (s *service) convertResources(container map[string]any) {
+	if s.Deploy == nil {
+		return
+	}
+	resources := map[string]any{}
+	for key, r := range map[string]Resources{"limits": s.Deploy.Resources.Limits, "requests": s.Deploy.Resources.Reservations} {
+		list := map[string]any{}
+		if r.Cpus != "" {
+			list["cpu"] = cpu(r.Cpus)
+		}
+		if r.Memory != "" {
+			list["memory"] = memory(r.Memory)
+		}
+		if len(list) > 0 {
+			resources[key] = list
+		}
+	}
+	if len(resources) > 0 {
+		container["resources"] = resources
+	}
+}
+
+// cpu converts a number of CPUs (0.5) into a Kubernetes quantity (500m).
+func cpu(cpus string) string {
+	f, err := strconv.ParseFloat(cpus, 64)
+	if err != nil || f == float64(int(f)) {
+		return cpus
+	}
+	return fmt.Sprintf("%dm", int(f*1000+0.5))
+}
+
+// memory converts a docker byte value (512m, 1gb) into a Kubernetes quantity
+// (512Mi, 1Gi).
+func memory(value string) string {
+	v := strings.TrimSuffix(strings.ToLower(value), "b")
+	if v == "" {
+		return value
+	}
+	suffix := map[byte]string{'k': "Ki", 'm': "Mi", 'g': "Gi", 't': "Ti"}[v[len(v)-1]]
+	if suffix == "" {
+		return v
+	}
+	return v[:len(v)-1] + suffix
+}
+
+// convertSecurity converts the user, privileged and cap_add fields into the
+// security context of the container.
+func (s *service) convertSecurity(container map[string]any) {
+	securityContext := map[string]any{}
+	if s.User != "" {
+		user, group, hasGroup := strings.Cut(s.User, ":")
+		uid, err := strconv.Atoi(user)
+		if err != nil {
+			s.warnings = append(s.warnings, "user "+s.User+" is not converted, runAsUser needs a numeric user")
+		} else {
+			securityContext["runAsUser"] = uid
+			if gid, err := strconv.Atoi(group); hasGroup && err == nil {
+				securityContext["runAsGroup"] = gid
+			}
+		}
+	}
+	if s.Privileged {
+		securityContext["privileged"] = true
+	}
+	if len(s.CapAdd) > 0 {
+		securityContext["capabilities"] = map[string]any{"add": toList(s.CapAdd)}
+	}
+	if len(securityContext) > 0 {
+		container["securityContext"] = securityContext
+	}
+}
+
+// convertDependencies adds an init container waiting for the first port of
+// each dependency. A dependency without port has no Service, it is listed in
+// the notes.
+func (s *service) convertDependencies(pod map[string]any) {
+	var initContainers []any
+	for _, name := range s.DependsOn {
+		dependency := s.project.service(name)
+		if dependency == nil {
+			s.warnings = append(s.warnings, "depends on the unknown service "+name)
+			continue
+		}
+		ports := s.project.newService(*dependency).ports
+		host := dnsName(name)
+		if len(ports) == 0 {
+			s.notes = append(s.notes, fmt.Sprintf("%s depends on %s, which declares no port: expose its ports in docker-compose to generate its Service", s.name, host))
+			continue
+		}
+		script := fmt.Sprintf("until nc -z %s %d; do echo waiting for %s; sleep 2; done", host, ports[0].number, host)
+		initContainers = append(initContainers, map[string]any{
+			"name":    "wait-for-" + host,
+			"image":   waitImage,
+			"command": []any{"sh", "-c", script},
+		})
+	}
+	if len(initContainers) > 0 {
+		pod["initContainers"] = initContainers
+	}
+}
+
+// volume is a volume mounted by a service.
+type volume struct {
+	kind     string
+	source   string
+	target   string
+	readOnly bool
+}
+
+// volumes returns the volumes and tmpfs mounted by the service.
+func (s *service) volumes() []volume {
+	var volumes []volume
+	for _, spec := range s.Volumes {
+		var v volume
+		if long, ok := spec.(map[string]any); ok {
+			v.kind, _ = long["type"].(string)
+			v.source, _ = long["source"].(string)
+			v.target, _ = long["target"].(string)
+			v.readOnly, _ = long["read_only"].(bool)
+		} else {
+			parts := strings.Split(fmt.Sprint(spec), ":")
+			switch len(parts) {
+			case 1:
+				v.target = parts[0]
+			default:
+				v.source, v.target = parts[0], parts[1]
+				v.readOnly = len(parts) > 2 && slices.Contains(strings.Split(parts[2], ","), "ro")
+			}
+			switch {
+			case v.source == "":
+				v.kind = "volume"
+			case strings.ContainsAny(v.source[:1], "./~"):
+				v.kind = "bind"
+			default:
+				v.kind = "volume"
+			}
+		}
+		volumes = append(volumes, v)
+	}
+	for _, target := range s.Tmpfs.values {
+		volumes = append(volumes, volume{kind: "tmpfs", target: strings.Split(target, ":")[0]})
+	}
+	return volumes
+}
+
+func (s *service) hasNamedVolume() bool {
+	return slices.ContainsFunc(s.volumes(), func(v volume) bool { return v.kind == "volume" && v.source != "" })
+}
+
+// convertVolumes mounts the volumes of the service: each named volume of a
+// StatefulSet is claimed by a volumeClaimTemplate, the tmpfs, the anonymous
+// volumes and the named volumes of a DaemonSet are emptyDir volumes and the
+// bind mounts are not converted.
+func (s *service) convertVolumes(workload importer.Object, container, pod map[string]any) {
+	var volumes, mounts, claims []any
+	for n, v := range s.volumes() {
+		if v.kind == "bind" {
+			s.warnings = append(s.warnings, fmt.Sprintf("bind mount %s:%s is not converted", v.source, v.target))
+			continue
+		}
+		name := fmt.Sprintf("%s-%d", v.kind, n)
+		if v.source != "" {
+			name = dnsName(v.source)
+		}
+		mount := map[string]any{"name": name, "mountPath": v.target}
+		if v.readOnly {
+			mount["readOnly"] = true
+		}
+		mounts = append(mounts, mount)
+
+		switch {
+		case v.kind == "volume" && v.source != "" && workload.Kind() == "StatefulSet":
+			claims = append(claims, map[string]any{
+				"metadata": map[string]any{"name": name},
+				"spec": map[string]any{
+					"accessModes": []any{"ReadWriteOnce"},
+					"resources":   map[string]any{"requests": map[string]any{"storage": "1Gi"}},
+				},
+			})
+		case v.kind == "tmpfs":
+			volumes = append(volumes, map[string]any{"name": name, "emptyDir": map[string]any{"medium": "Memory"}})
+		default:
+			if v.source != "" {
+				s.warnings = append(s.warnings, "volume "+v.source+" is an emptyDir, its data is not persisted")
+			}
+			volumes = append(volumes, map[string]any{"name": name, "emptyDir": map[string]any{}})
+		}
+	}
+	if len(claims) > 0 {
+		workload["spec"].(map[string]any)["volumeClaimTemplates"] = claims
+	}
+	if len(volumes) > 0 {
+		pod["volumes"] = volumes
+	}
+	if len(mounts) > 0 {
+		container["volumeMounts"] = mounts
+	}
+}
+
+// warnUnsupported warns about the keys of the service which are not converted.
+func (s *service) warnUnsupported() {
+	var keys []string
+	for key := range s.Unsupported {
+		if !slices.Contains(ignoredKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		s.warnings = append(s.warnings, key+" is not converted")
+	}
+}
+
+// split returns the arguments of a command, a string command is split as a
+// shell would.
+func (s stringOrList) split() []string {
+	if !s.isString {
+		return s.values
+	}
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s.values[0] {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// nonDNS matches the characters replaced in the DNS labels.
+var nonDNS = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dnsName converts a compose name into a DNS label: lowercase letters, digits
+// and hyphens, starting with a letter.
+func dnsName(name string) string {
+	name = strings.Trim(nonDNS.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "svc-" + name
+	}
+	return name
+}
+
+func toList(values []string) []any {
+	list := make([]any, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
@@ -80,6 +80,14 @@ func (fs *OSFileSystem) Walk(root string, fn filepath.WalkFunc) error {
 	return nil
 }
 
+// Remove removes the named file.
+func (fs *OSFileSystem) Remove(name string) error {
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove file %s: %w", name, err)
+	}
+	return nil
+}
+
 // DefaultTemplateProcessor implements TemplateProcessor interface.
 type DefaultTemplateProcessor struct{}
 
@@ -0,0 +1,743 @@
+// Package importer generates a chart from existing Kubernetes manifests, such
+// as the output of kubectl get -o yaml.
+//
+// Import Flow:
+//  1. Read the objects of the manifests (files, directories or stdin), the
+//     items of List objects are imported separately
+//  2. Strip the fields managed by the cluster (status, uid, resourceVersion,
+//     managedFields, kubectl annotations, allocated cluster IPs, ...)
+//  3. Select the main workload (the first Deployment, StatefulSet, DaemonSet or
+//     CronJob) and the Service, Ingress, ConfigMap, PersistentVolumeClaim,
+//     ServiceAccount and HorizontalPodAutoscaler of its chart
+//  4. Generate the chart with pkg/app and set the extracted values (image,
+//     command, replicas, ports, resources, env, probes, ...) in values.yaml
+//
+// ImportUmbrella generates an umbrella chart instead, with one component chart
+// per set of objects.
+//
+// The other objects are kept as is in the extraObjects values, with their
+// template delimiters escaped from tpl, the returned warnings list them.
+// fullnameOverride is set to the name of the workload so that the resources of
+// the chart keep its name.
+package importer
+
+import (
+	"embed"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
+)
+
+// Importer generates charts from Kubernetes manifests.
+type Importer struct {
+	fs                interfaces.FileSystem
+	templateProcessor interfaces.TemplateProcessor
+	pathManager       interfaces.PathManager
+	chartTemplate     embed.FS
+}
+
+// NewImporter creates an importer generating the charts with the given
+// dependencies, as app.NewApp does.
+func NewImporter(fs interfaces.FileSystem, templateProcessor interfaces.TemplateProcessor,
+	pathManager interfaces.PathManager, chartTemplate embed.FS) *Importer {
+	return &Importer{
+		fs:                fs,
+		templateProcessor: templateProcessor,
+		pathManager:       pathManager,
+		chartTemplate:     chartTemplate,
+	}
+}
+
+// Chart is a chart to generate from objects, the notes are added to its
+// NOTES.txt.
+type Chart struct {
+	Name    string
+	Objects []Object
+	Notes   []string
+}
+
+// Import generates the chart chartName in chartPath from the objects. It
+// returns the warnings about the objects that are not imported in the values
+// of the chart resources.
+func (i *Importer) Import(chartName, chartPath string, objects []Object) ([]string, error) {
+	return i.ImportChart(chartPath, Chart{Name: chartName, Objects: objects})
+}
+
+// ImportChart generates the chart in chartPath, as Import does.
+func (i *Importer) ImportChart(chartPath string, chart Chart) ([]string, error) {
+	c, err := convert(chart.Objects)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.ValidationError, "import", "failed to import manifests").
+			WithChart(chart.Name)
+	}
+
+	generator := app.NewApp(chart.Name, chartPath, i.fs, i.templateProcessor, i.pathManager, i.chartTemplate)
+	c.configure(generator)
+	generator.SetNotes(chart.Notes)
+	if err := generator.GenerateChart(); err != nil {
+		return nil, err
+	}
+	if err := i.importValues(chart.Name, chartPath, c.values); err != nil {
+		return nil, err
+	}
+	return c.warnings, nil
+}
+
+// ImportUmbrella generates the umbrella chart chartName in chartPath with one
+// component chart per chart of objects. The warnings are prefixed with the
+// name of their component.
+func (i *Importer) ImportUmbrella(chartName, chartPath string, charts []Chart) ([]string, error) {
+	umbrella := app.NewUmbrella(chartName, chartPath, i.fs, i.templateProcessor, i.pathManager, i.chartTemplate)
+	conversions := make([]*conversion, 0, len(charts))
+	for _, chart := range charts {
+		c, err := convert(chart.Objects)
+		if err != nil {
+			return nil, errors.WrapError(err, errors.ValidationError, "import", "failed to import manifests").
+				WithChart(chartName).
+				WithContext("component", chart.Name)
+		}
+		component := umbrella.AddComponent(chart.Name)
+		c.configure(component)
+		component.SetNotes(chart.Notes)
+		conversions = append(conversions, c)
+	}
+	if err := umbrella.GenerateChart(); err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for n, c := range conversions {
+		name := charts[n].Name
+		if err := i.importValues(name, i.pathManager.Join(chartPath, "charts", name), c.values); err != nil {
+			return nil, err
+		}
+		for _, w := range c.warnings {
+			warnings = append(warnings, name+": "+w)
+		}
+	}
+	return warnings, nil
+}
+
+// importValues sets the imported values in the values.yaml of the chart.
+func (i *Importer) importValues(chartName, chartPath string, values []value) error {
+	valuesPath := i.pathManager.Join(chartPath, "values.yaml")
+	content, err := i.fs.ReadFile(valuesPath)
+	if err != nil {
+		return errors.NewFileSystemError("import", "failed to read values.yaml", err).
+			WithChart(chartName).
+			WithFile(valuesPath)
+	}
+	content, err = setValues(content, values)
+	if err != nil {
+		return errors.WrapError(err, errors.ValidationError, "import", "failed to import values").
+			WithChart(chartName)
+	}
+	const filePerm = 0644
+	if err := i.fs.WriteFile(valuesPath, content, filePerm); err != nil {
+		return errors.NewFileSystemError("import", "failed to write values.yaml", err).
+			WithChart(chartName).
+			WithFile(valuesPath)
+	}
+	return nil
+}
+
+// conversion holds the objects selected for the chart resources and the values
+// extracted from them.
+type conversion struct {
+	workload       Object
+	podTemplate    map[string]any
+	pod            map[string]any
+	container      map[string]any
+	service        Object
+	ingress        Object
+	configMap      Object
+	claim          Object
+	serviceAccount string
+	createAccount  bool
+	hpa            Object
+	ports          []app.Port
+	portValues     []any
+	extraObjects   []any
+	values         []value
+	warnings       []string
+}
+
+// workloadKinds are the kinds of the workloads of the chart.
+var workloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "CronJob"}
+
+// chartPodAnnotations are the pod annotations written by the workload templates
+// of the chart, they are not imported when the manifests were rendered from a
+// chart generated by this tool.
+var chartPodAnnotations = []string{"checksum/config", "checksum/config-files", "rollme"}
+
+// convert selects the objects of the chart resources and extracts their values.
+func convert(objects []Object) (*conversion, error) {
+	c := &conversion{}
+	for _, object := range objects {
+		stripClusterFields(object)
+		if c.workload == nil && slices.Contains(workloadKinds, object.Kind()) {
+			c.workload = object
+		}
+	}
+	if c.workload == nil {
+		return nil, errors.NewValidationError("import",
+			"no "+strings.Join(workloadKinds, ", ")+" to import")
+	}
+
+	c.podTemplate = lookupMap(c.workload, "spec", "template")
+	if c.workload.Kind() == "CronJob" {
+		c.podTemplate = lookupMap(c.workload, "spec", "jobTemplate", "spec", "template")
+	}
+	c.pod = lookupMap(c.podTemplate, "spec")
+	containers := lookupList(c.pod, "containers")
+	if len(containers) == 0 {
+		return nil, errors.NewValidationError("import", "the workload has no container").
+			WithContext("object", c.workload.String())
+	}
+	c.container, _ = containers[0].(map[string]any)
+
+	c.selectObjects(objects)
+	c.convertWorkload()
+	c.convertPorts()
+	c.convertService()
+	c.convertIngress()
+	c.convertConfigMap()
+	c.convertVolumes()
+	c.convertServiceAccount()
+	c.convertHpa()
+	if len(c.extraObjects) > 0 {
+		c.set("extraObjects", c.extraObjects)
+	}
+	return c, nil
+}
+
+// selectObjects selects the objects of the chart resources, the other objects
+// are kept as extra objects.
+func (c *conversion) selectObjects(objects []Object) {
+	configMapName, _ := lookup(c.envFromConfigMap(), "configMapRef", "name").(string)
+	claimName := ""
+	for _, volume := range lookupList(c.pod, "volumes") {
+		if name, ok := lookup(volume, "persistentVolumeClaim", "claimName").(string); ok && claimName == "" {
+			claimName = name
+		}
+	}
+	c.serviceAccount, _ = c.pod["serviceAccountName"].(string)
+	headlessName, _ := lookup(c.workload, "spec", "serviceName").(string)
+
+	for _, object := range objects {
+		name := object.Name()
+		switch {
+		case object.String() == c.workload.String():
+			continue
+		case object.Kind() == "Service" && c.workload.Kind() == "StatefulSet" && name == headlessName &&
+			lookup(object, "spec", "clusterIP") == "None":
+			// the chart generates the headless service of the statefulset
+			continue
+		case object.Kind() == "Service" && c.service == nil && lookup(object, "spec", "clusterIP") != "None":
+			c.service = object
+			continue
+		case object.Kind() == "Ingress" && c.ingress == nil:
+			c.ingress = object
+			continue
+		case object.Kind() == "ConfigMap" && c.configMap == nil && name == configMapName:
+			c.configMap = object
+			continue
+		case object.Kind() == "PersistentVolumeClaim" && c.claim == nil && name == claimName &&
+			c.workload.Kind() != "StatefulSet":
+			c.claim = object
+			continue
+		case object.Kind() == "ServiceAccount" && !c.createAccount && name == c.serviceAccount:
+			c.createAccount = true
+			if annotations := lookupMap(object, "metadata", "annotations"); annotations != nil {
+				c.set("serviceAccount.annotations", annotations)
+			}
+			continue
+		case object.Kind() == "HorizontalPodAutoscaler" && c.hpa == nil &&
+			lookup(object, "spec", "scaleTargetRef", "kind") == c.workload.Kind() &&
+			lookup(object, "spec", "scaleTargetRef", "name") == c.workload.Name() &&
+			c.workload.Kind() != "DaemonSet" && c.workload.Kind() != "CronJob":
+			c.hpa = object
+			continue
+		}
+		extra, escaped := escapeTemplates(map[string]any(object))
+		c.extraObjects = append(c.extraObjects, extra)
+		msg := "imported as is in extraObjects"
+		if object.Kind() == "Secret" {
+			msg += ", its data is stored in values.yaml"
+		}
+		if escaped {
+			msg += ", its template delimiters are escaped from tpl"
+		}
+		c.warn(object, msg)
+	}
+}
+
+// configure selects the resources of the chart.
+func (c *conversion) configure(chart *app.App) {
+	chart.SetDeployment(c.workload.Kind() == "Deployment")
+	chart.SetStatefulSet(c.workload.Kind() == "StatefulSet")
+	chart.SetDaemonSet(c.workload.Kind() == "DaemonSet")
+	chart.SetCronjob(c.workload.Kind() == "CronJob")
+	chart.SetService(c.service != nil)
+	chart.SetIngress(c.ingress != nil)
+	chart.SetConfigmap(c.configMap != nil)
+	chart.SetVolumes(c.claim != nil || len(lookupList(c.workload, "spec", "volumeClaimTemplates")) > 0)
+	chart.SetServiceAccount(c.serviceAccount != "")
+	chart.SetHpa(c.hpa != nil)
+	chart.SetPorts(c.ports)
+	chart.SetExtraObjects(len(c.extraObjects) > 0)
+}
+
+// convertWorkload extracts the values of the workload, of its pod and of its
+// main container.
+func (c *conversion) convertWorkload() {
+	c.set("fullnameOverride", c.workload.Name())
+	if c.workload.Kind() == "Deployment" || c.workload.Kind() == "StatefulSet" {
+		c.set("replicaCount", valueOr(lookup(c.workload, "spec", "replicas"), 1))
+	}
+
+	image, _ := c.container["image"].(string)
+	repository, tag := splitImage(image)
+	c.set("image.repository", repository)
+	c.set("image.tag", tag)
+	c.setFrom(c.container, "imagePullPolicy", "image.pullPolicy")
+	c.setFrom(c.container, "command", "command")
+	c.setFrom(c.container, "args", "args")
+	c.setFrom(c.container, "resources", "resources")
+	c.setFrom(c.container, "env", "env")
+	c.setFrom(c.container, "securityContext", "securityContext")
+	if c.workload.Kind() != "CronJob" {
+		// the probes of the chart are replaced, even when the container has none
+		for _, probe := range []string{"startupProbe", "livenessProbe", "readinessProbe"} {
+			c.set(probe, valueOr(c.container[probe], map[string]any{}))
+		}
+	}
+
+	podMetadata := lookupMap(c.podTemplate, "metadata")
+	stripAnnotations(podMetadata, chartPodAnnotations)
+	c.setFrom(podMetadata, "annotations", "podAnnotations")
+	c.setFrom(c.pod, "securityContext", "podSecurityContext")
+	for _, key := range []string{
+		"imagePullSecrets", "nodeSelector", "tolerations", "affinity", "topologySpreadConstraints",
+		"priorityClassName", "terminationGracePeriodSeconds", "dnsConfig", "hostAliases", "runtimeClassName",
+	} {
+		c.setFrom(c.pod, key, key)
+	}
+	if dnsPolicy, ok := c.pod["dnsPolicy"].(string); ok && dnsPolicy != "ClusterFirst" {
+		c.set("dnsPolicy", dnsPolicy)
+	}
+	if scheduler, ok := c.pod["schedulerName"].(string); ok && scheduler != "default-scheduler" {
+		c.set("schedulerName", scheduler)
+	}
+	if initContainers := lookupList(c.pod, "initContainers"); len(initContainers) > 0 {
+		c.set("initContainers", stripContainers(initContainers))
+	}
+	if sidecars := lookupList(c.pod, "containers")[1:]; len(sidecars) > 0 {
+		c.set("sidecars", stripContainers(sidecars))
+	}
+
+	if c.workload.Kind() == "CronJob" {
+		spec := lookupMap(c.workload, "spec")
+		for _, key := range []string{"schedule", "concurrencyPolicy", "suspend", "failedJobsHistoryLimit", "successfulJobsHistoryLimit"} {
+			c.setFrom(spec, key, key)
+		}
+		c.setFrom(lookupMap(spec, "jobTemplate", "spec"), "backoffLimit", "backoffLimit")
+		c.setFrom(c.pod, "restartPolicy", "restartPolicy")
+	}
+	c.checkSelector()
+}
+
+// convertPorts extracts the ports of the main container, the ports targeted by
+// the service are added when the container does not declare them.
+func (c *conversion) convertPorts() {
+	for _, p := range lookupList(c.container, "ports") {
+		number, ok := lookup(p, "containerPort").(int)
+		if !ok {
+			continue
+		}
+		name, _ := lookup(p, "name").(string)
+		c.addPort(name, number, valueOr(lookup(p, "protocol"), "TCP"))
+	}
+	for _, sp := range lookupList(c.service, "spec", "ports") {
+		if c.containerPort(lookup(sp, "targetPort"), lookup(sp, "port")) < 0 {
+			number, ok := valueOr(lookup(sp, "targetPort"), lookup(sp, "port")).(int)
+			if !ok {
+				c.warn(c.service, fmt.Sprintf("target port %v is not a port of the container", lookup(sp, "targetPort")))
+				continue
+			}
+			name, _ := lookup(sp, "name").(string)
+			c.addPort(name, number, valueOr(lookup(sp, "protocol"), "TCP"))
+		}
+	}
+
+	// the ingress routes to the first port of the service
+	if backend := c.ingressBackendPort(); backend != nil {
+		for _, sp := range lookupList(c.service, "spec", "ports") {
+			if lookup(sp, "name") != backend && lookup(sp, "port") != backend {
+				continue
+			}
+			if i := c.containerPort(lookup(sp, "targetPort"), lookup(sp, "port")); i >= 0 {
+				c.portValues[0], c.portValues[i] = c.portValues[i], c.portValues[0]
+				c.ports[0], c.ports[i] = c.ports[i], c.ports[0]
+			}
+			break
+		}
+	}
+	if len(c.portValues) > 0 {
+		c.set("ports", c.portValues)
+	}
+}
+
+// addPort adds a port of the main container, the unnamed ports are named
+// port-<number>.
+func (c *conversion) addPort(name string, number int, protocol any) {
+	if name == "" {
+		name = fmt.Sprintf("port-%d", number)
+	}
+	c.ports = append(c.ports, app.Port{Name: name, Number: number})
+	c.portValues = append(c.portValues, map[string]any{"name": name, "containerPort": number, "protocol": protocol})
+}
+
+// containerPort returns the index of the port of the main container targeted
+// by a service port, -1 when there is none.
+func (c *conversion) containerPort(targetPort, port any) int {
+	targetPort = valueOr(targetPort, port)
+	for i, p := range c.portValues {
+		if lookup(p, "name") == targetPort || lookup(p, "containerPort") == targetPort {
+			return i
+		}
+	}
+	return -1
+}
+
+// convertService sets the type of the service and the service port of the
+// container ports.
+func (c *conversion) convertService() {
+	if c.service == nil {
+		return
+	}
+	c.set("service.type", valueOr(lookup(c.service, "spec", "type"), "ClusterIP"))
+	for _, sp := range lookupList(c.service, "spec", "ports") {
+		if i := c.containerPort(lookup(sp, "targetPort"), lookup(sp, "port")); i >= 0 {
+			p := c.portValues[i].(map[string]any)
+			p["servicePort"] = lookup(sp, "port")
+			if nodePort := lookup(sp, "nodePort"); nodePort != nil {
+				p["nodePort"] = nodePort
+			}
+			if appProtocol := lookup(sp, "appProtocol"); appProtocol != nil {
+				p["appProtocol"] = appProtocol
+			}
+		}
+	}
+	c.checkName(c.service)
+}
+
+// ingressBackendPort returns the service port (number or name) of the first
+// backend of the ingress.
+func (c *conversion) ingressBackendPort() any {
+	for _, rule := range lookupList(c.ingress, "spec", "rules") {
+		for _, path := range lookupList(rule, "http", "paths") {
+			port := lookupMap(path, "backend", "service", "port")
+			return valueOr(port["number"], port["name"])
+		}
+	}
+	return nil
+}
+
+// convertIngress extracts the hosts, the paths and the TLS configuration of
+// the ingress.
+func (c *conversion) convertIngress() {
+	if c.ingress == nil {
+		return
+	}
+	if c.service == nil {
+		c.warn(c.ingress, "routes to the service of the chart, which is only generated with a Service")
+	}
+	var hosts []any
+	for _, rule := range lookupList(c.ingress, "spec", "rules") {
+		var paths []any
+		for _, path := range lookupList(rule, "http", "paths") {
+			paths = append(paths, map[string]any{
+				"path":     valueOr(lookup(path, "path"), "/"),
+				"pathType": valueOr(lookup(path, "pathType"), "ImplementationSpecific"),
+			})
+		}
+		hosts = append(hosts, map[string]any{"host": valueOr(lookup(rule, "host"), ""), "paths": paths})
+	}
+	c.set("ingress.enabled", true)
+	c.setFrom(lookupMap(c.ingress, "spec"), "ingressClassName", "ingress.className")
+	c.setFrom(lookupMap(c.ingress, "metadata"), "annotations", "ingress.annotations")
+	c.set("ingress.hosts", hosts)
+	c.setFrom(lookupMap(c.ingress, "spec"), "tls", "ingress.tls")
+	c.checkName(c.ingress)
+}
+
+// convertConfigMap sets the data of the ConfigMap loaded by the main container
+// as configuration, the other envFrom sources become additionalEnvFrom.
+func (c *conversion) convertConfigMap() {
+	var envFrom []any
+	for _, source := range lookupList(c.container, "envFrom") {
+		if c.configMap != nil && lookup(source, "configMapRef", "name") == c.configMap.Name() {
+			continue
+		}
+		envFrom = append(envFrom, source)
+	}
+	if c.configMap == nil {
+		if len(envFrom) > 0 {
+			c.warn(c.workload, "envFrom is only imported with the ConfigMap it loads")
+		}
+		return
+	}
+	c.set("configuration", valueOr(lookup(c.configMap, "data"), map[string]any{}))
+	if len(envFrom) > 0 {
+		c.set("additionalEnvFrom", envFrom)
+	}
+	if lookup(c.configMap, "binaryData") != nil {
+		c.warn(c.configMap, "binaryData is not imported")
+	}
+	c.checkName(c.configMap)
+}
+
+// convertVolumes extracts the volumes of the pod and the persistence of the
+// chart: the claim mounted by the pod, or the volumeClaimTemplates of a
+// statefulset (the first one is the data volume of the chart).
+func (c *conversion) convertVolumes() {
+	volumes := lookupList(c.pod, "volumes")
+	mounts := lookupList(c.container, "volumeMounts")
+
+	if c.claim != nil {
+		spec := lookupMap(c.claim, "spec")
+		c.setPersistence(spec, lookupMap(c.claim, "metadata", "annotations"))
+		c.checkName(c.claim)
+		// the claim of the chart is named after the fullname
+		for _, volume := range volumes {
+			if claim := lookupMap(volume, "persistentVolumeClaim"); claim["claimName"] == c.claim.Name() {
+				claim["claimName"] = c.workload.Name()
+			}
+		}
+	}
+	if templates := lookupList(c.workload, "spec", "volumeClaimTemplates"); len(templates) > 0 {
+		name, _ := lookup(templates[0], "metadata", "name").(string)
+		c.setPersistence(lookupMap(templates[0], "spec"), lookupMap(templates[0], "metadata", "annotations"))
+		// the chart mounts the claimed volume as data
+		var kept []any
+		for _, mount := range mounts {
+			if lookup(mount, "name") == name {
+				c.set("persistence.mountPath", lookup(mount, "mountPath"))
+				continue
+			}
+			kept = append(kept, mount)
+		}
+		mounts = kept
+		if len(templates) > 1 {
+			// the other claimed volumes keep their name and their mount
+			c.set("persistence.extraVolumeClaimTemplates", templates[1:])
+		}
+	}
+	if c.configMap != nil {
+		for _, volume := range volumes {
+			if configMap := lookupMap(volume, "configMap"); configMap["name"] == c.configMap.Name() {
+				configMap["name"] = c.workload.Name()
+			}
+		}
+	}
+
+	if len(volumes) > 0 {
+		c.set("volumes", volumes)
+	}
+	if len(mounts) > 0 {
+		c.set("volumeMounts", mounts)
+	}
+}
+
+func (c *conversion) setPersistence(spec, annotations map[string]any) {
+	c.set("persistence.size", valueOr(lookup(spec, "resources", "requests", "storage"), "1Gi"))
+	c.setFrom(spec, "accessModes", "persistence.accessModes")
+	c.setFrom(spec, "storageClassName", "persistence.storageClassName")
+	if annotations != nil {
+		c.set("persistence.annotations", annotations)
+	}
+}
+
+// convertServiceAccount keeps the service account of the pod, it is created by
+// the chart when it is part of the manifests.
+func (c *conversion) convertServiceAccount() {
+	if c.serviceAccount == "" {
+		return
+	}
+	c.set("serviceAccount.create", c.createAccount)
+	c.set("serviceAccount.name", c.serviceAccount)
+}
+
+// convertHpa extracts the replicas bounds, the metrics and the behavior of the
+// horizontal pod autoscaler.
+func (c *conversion) convertHpa() {
+	if c.hpa == nil {
+		return
+	}
+	spec := lookupMap(c.hpa, "spec")
+	c.set("autoscaling.enabled", true)
+	c.set("autoscaling.minReplicas", valueOr(spec["minReplicas"], 1))
+	c.setFrom(spec, "maxReplicas", "autoscaling.maxReplicas")
+
+	utilization := map[string]any{"cpu": nil, "memory": nil}
+	var metrics []any
+	for _, metric := range lookupList(spec, "metrics") {
+		name, _ := lookup(metric, "resource", "name").(string)
+		if lookup(metric, "type") == "Resource" && lookup(metric, "resource", "target", "type") == "Utilization" &&
+			(name == "cpu" || name == "memory") {
+			utilization[name] = lookup(metric, "resource", "target", "averageUtilization")
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	c.set("autoscaling.targetCPUUtilizationPercentage", utilization["cpu"])
+	if utilization["memory"] != nil {
+		c.set("autoscaling.targetMemoryUtilizationPercentage", utilization["memory"])
+	}
+	if len(metrics) > 0 {
+		c.set("autoscaling.metrics", metrics)
+	}
+	c.setFrom(spec, "behavior", "autoscaling.behavior")
+	c.checkName(c.hpa)
+}
+
+// checkName warns when a resource of the chart is renamed: the resources are
+// named after the fullname, which is the name of the workload.
+func (c *conversion) checkName(object Object) {
+	if object.Name() != c.workload.Name() {
+		c.warn(object, "renamed to "+c.workload.Name())
+	}
+}
+
+// chartSelectorLabels are the labels of the selector of the chart workloads.
+var chartSelectorLabels = []string{"app.kubernetes.io/instance", "app.kubernetes.io/name"}
+
+// checkSelector warns when the pods of the workload are selected by other
+// labels than the selector labels of the chart: the objects selecting them
+// (extra objects, services of other charts, ...) select nothing once the
+// chart is installed, and the selector of a Deployment, a StatefulSet or a
+// DaemonSet is immutable.
+func (c *conversion) checkSelector() {
+	selector := lookupMap(c.workload, "spec", "selector")
+	labels := lookupMap(selector, "matchLabels")
+	if c.workload.Kind() == "CronJob" {
+		labels = lookupMap(c.podTemplate, "metadata", "labels")
+	}
+	keys := slices.Sorted(maps.Keys(labels))
+	if len(keys) == 0 || slices.Equal(keys, chartSelectorLabels) && selector["matchExpressions"] == nil {
+		return
+	}
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, labels[key]))
+	}
+	if c.workload.Kind() == "CronJob" {
+		c.warn(c.workload, "pod labels "+strings.Join(pairs, ",")+
+			" replaced by the selector labels of the chart, update the objects selecting the pods")
+		return
+	}
+	c.warn(c.workload, "selector "+strings.Join(pairs, ",")+
+		" replaced by the selector labels of the chart, update the objects selecting the pods"+
+		" and delete the "+c.workload.Kind()+" before installing the chart, its selector is immutable")
+}
+
+// set sets a value of the chart.
+func (c *conversion) set(path string, v any) {
+	c.values = append(c.values, value{path: path, value: v})
+}
+
+// setFrom sets a value of the chart from the field of an object, when it is set.
+func (c *conversion) setFrom(m map[string]any, key, path string) {
+	if v, ok := m[key]; ok && v != nil {
+		c.set(path, v)
+	}
+}
+
+func (c *conversion) warn(object Object, msg string) {
+	c.warnings = append(c.warnings, object.String()+": "+msg)
+}
+
+// envFromConfigMap returns the first envFrom source of the main container
+// loading a ConfigMap.
+func (c *conversion) envFromConfigMap() any {
+	for _, source := range lookupList(c.container, "envFrom") {
+		if lookup(source, "configMapRef") != nil {
+			return source
+		}
+	}
+	return nil
+}
+
+// splitImage splits an image into its repository and its tag, latest when the
+// image has none. A digest is kept in the repository and the tag so that
+// repository:tag is the image.
+func splitImage(image string) (string, string) {
+	slash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > slash {
+		return image[:colon], image[colon+1:]
+	}
+	return image, "latest"
+}
+
+// stripContainers removes the fields defaulted by the cluster from containers.
+func stripContainers(containers []any) []any {
+	for _, container := range containers {
+		if container, ok := container.(map[string]any); ok {
+			if container["terminationMessagePath"] == "/dev/termination-log" {
+				delete(container, "terminationMessagePath")
+			}
+			if container["terminationMessagePolicy"] == "File" {
+				delete(container, "terminationMessagePolicy")
+			}
+		}
+	}
+	return containers
+}
+
+// tplDelimiters escapes the template delimiters, the extraObjects are
+// rendered through tpl by extra-manifests.yaml.
+var tplDelimiters = strings.NewReplacer("{{", "{{`{{`}}", "}}", "{{`}}`}}")
+
+// escapeTemplates returns a copy of v with the template delimiters of its keys
+// and strings escaped, and whether any was found.
+func escapeTemplates(v any) (any, bool) {
+	switch v := v.(type) {
+	case string:
+		escaped := tplDelimiters.Replace(v)
+		return escaped, escaped != v
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		found := false
+		for key, item := range v {
+			escapedKey := tplDelimiters.Replace(key)
+			escapedItem, escaped := escapeTemplates(item)
+			m[escapedKey] = escapedItem
+			found = found || escaped || escapedKey != key
+		}
+		return m, found
+	case []any:
+		list := make([]any, len(v))
+		found := false
+		for i, item := range v {
+			escapedItem, escaped := escapeTemplates(item)
+			list[i] = escapedItem
+			found = found || escaped
+		}
+		return list, found
+	}
+	return v, false
+}
+
+// valueOr returns v, or def when v is nil.
+func valueOr(v, def any) any {
+	if v == nil {
+		return def
+	}
+	return v
+}
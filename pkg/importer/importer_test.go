@@ -0,0 +1,544 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/filesystem"
+)
+
+const webManifests = `apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+    namespace: shop
+    uid: 0a1b
+    resourceVersion: "12345"
+    annotations:
+      deployment.kubernetes.io/revision: "3"
+  spec:
+    replicas: 3
+    template:
+      spec:
+        serviceAccountName: web
+        containers:
+        - name: web
+          image: registry.example.com/shop/web:1.4.2
+          args: ["--listen", ":8080"]
+          envFrom:
+          - configMapRef:
+              name: web-config
+          env:
+          - name: LOG_LEVEL
+            value: info
+          ports:
+          - containerPort: 8080
+            name: http
+          readinessProbe:
+            httpGet:
+              path: /ready
+              port: http
+          resources:
+            requests:
+              cpu: 100m
+          terminationMessagePath: /dev/termination-log
+        - name: proxy
+          image: envoyproxy/envoy:v1.29
+          terminationMessagePath: /dev/termination-log
+        volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: web-data
+  status:
+    availableReplicas: 3
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+  spec:
+    clusterIP: 10.0.0.12
+    type: NodePort
+    ports:
+    - name: admin
+      port: 9000
+    - name: http
+      port: 80
+      targetPort: http
+      nodePort: 30080
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              name: http
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  MODE: production
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: web-data
+spec:
+  accessModes: [ReadWriteOnce]
+  volumeName: pvc-123
+  resources:
+    requests:
+      storage: 5Gi
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef:
+    kind: Deployment
+    name: web
+  maxReplicas: 12
+  metrics:
+  - type: Resource
+    resource:
+      name: memory
+      target:
+        type: Utilization
+        averageUtilization: 70
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: shop
+data:
+  password: cGFzcw==
+`
+
+const dbManifests = `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  serviceName: db-headless
+  template:
+    spec:
+      containers:
+      - name: postgres
+        image: postgres
+        volumeMounts:
+        - name: pgdata
+          mountPath: /var/lib/postgresql/data
+        - name: run
+          mountPath: /run
+        - name: wal
+          mountPath: /var/lib/postgresql/wal
+  volumeClaimTemplates:
+  - metadata:
+      name: pgdata
+    spec:
+      storageClassName: fast
+      resources:
+        requests:
+          storage: 20Gi
+  - metadata:
+      name: wal
+    spec:
+      resources:
+        requests:
+          storage: 5Gi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: db-headless
+spec:
+  clusterIP: None
+  ports:
+  - port: 5432
+`
+
+const backupManifests = `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  schedule: "0 3 * * *"
+  concurrencyPolicy: Forbid
+  jobTemplate:
+    spec:
+      backoffLimit: 2
+      template:
+        spec:
+          restartPolicy: Never
+          containers:
+          - name: backup
+            image: backup@sha256:abcd
+`
+
+func TestReadManifests(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"manifests/web.yaml":   webManifests,
+		"manifests/db.yml":     dbManifests,
+		"manifests/README.md":  "# not a manifest",
+		"backup.txt":           backupManifests,
+		"invalid/invalid.yaml": "kind: [",
+		"nokind/nokind.yaml":   "---\napiVersion: v1\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i := NewImporter(filesystem.NewOSFileSystem(), filesystem.NewDefaultTemplateProcessor(),
+		filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+
+	tests := []struct {
+		name        string
+		sources     []string
+		stdin       string
+		expected    []string
+		errContains string
+	}{
+		{
+			name:    "directory and file",
+			sources: []string{filepath.Join(dir, "manifests"), filepath.Join(dir, "backup.txt")},
+			expected: []string{
+				"StatefulSet/db", "Service/db-headless",
+				"Deployment/web", "Service/web", "Ingress/web", "ConfigMap/web-config",
+				"PersistentVolumeClaim/web-data", "HorizontalPodAutoscaler/web", "Secret/db",
+				"CronJob/backup",
+			},
+		},
+		{
+			name:     "stdin",
+			sources:  []string{Stdin},
+			stdin:    "---\n" + backupManifests,
+			expected: []string{"CronJob/backup"},
+		},
+		{
+			name:        "missing source",
+			sources:     []string{filepath.Join(dir, "missing")},
+			errContains: "failed to read manifests",
+		},
+		{
+			name:        "invalid manifest",
+			sources:     []string{filepath.Join(dir, "invalid")},
+			errContains: "invalid manifest",
+		},
+		{
+			name:        "object without kind",
+			sources:     []string{filepath.Join(dir, "nokind")},
+			errContains: "the object has no kind",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := i.ReadManifests(tt.sources, strings.NewReader(tt.stdin))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("ReadManifests() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadManifests() error = %v", err)
+			}
+			var got []string
+			for _, object := range objects {
+				got = append(got, object.String())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ReadManifests() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImporter_Import(t *testing.T) {
+	tests := []struct {
+		name          string
+		manifests     string
+		expectedFiles []string
+		values        map[string]any
+		warnings      []string
+		errContains   string
+	}{
+		{
+			name:      "deployment",
+			manifests: webManifests,
+			expectedFiles: []string{
+				"templates/deployment.yaml", "templates/service.yaml", "templates/ingress.yaml",
+				"templates/configmap.yaml", "templates/pvc.yaml", "templates/serviceaccount.yaml",
+				"templates/hpa.yaml", "templates/extra-manifests.yaml",
+			},
+			values: map[string]any{
+				"fullnameOverride": "web",
+				"replicaCount":     3,
+				"image":            map[string]any{"repository": "registry.example.com/shop/web", "tag": "1.4.2", "pullPolicy": "IfNotPresent"},
+				"args":             []any{"--listen", ":8080"},
+				"env":              []any{map[string]any{"name": "LOG_LEVEL", "value": "info"}},
+				"resources":        map[string]any{"requests": map[string]any{"cpu": "100m"}},
+				"ports": []any{
+					map[string]any{"name": "http", "containerPort": 8080, "servicePort": 80, "nodePort": 30080, "protocol": "TCP"},
+					map[string]any{"name": "admin", "containerPort": 9000, "servicePort": 9000, "protocol": "TCP"},
+				},
+				"service":        map[string]any{"type": "NodePort"},
+				"livenessProbe":  map[string]any{},
+				"readinessProbe": map[string]any{"httpGet": map[string]any{"path": "/ready", "port": "http"}},
+				"sidecars":       []any{map[string]any{"name": "proxy", "image": "envoyproxy/envoy:v1.29"}},
+				"configuration":  map[string]any{"MODE": "production"},
+				"volumes": []any{
+					map[string]any{"name": "data", "persistentVolumeClaim": map[string]any{"claimName": "web"}},
+				},
+				"serviceAccount": map[string]any{"create": false, "annotations": map[string]any{}, "name": "web"},
+			},
+			warnings: []string{
+				"Secret/db: imported as is in extraObjects, its data is stored in values.yaml",
+				"ConfigMap/web-config: renamed to web",
+				"PersistentVolumeClaim/web-data: renamed to web",
+			},
+		},
+		{
+			name:          "statefulset",
+			manifests:     dbManifests,
+			expectedFiles: []string{"templates/statefulset.yaml", "templates/service-headless.yaml"},
+			values: map[string]any{
+				"image":        map[string]any{"repository": "postgres", "tag": "latest", "pullPolicy": "IfNotPresent"},
+				"replicaCount": 1,
+				"volumeMounts": []any{
+					map[string]any{"name": "run", "mountPath": "/run"},
+					map[string]any{"name": "wal", "mountPath": "/var/lib/postgresql/wal"},
+				},
+				"persistence": map[string]any{
+					"enabled": true, "storageClassName": "fast", "accessModes": []any{"ReadWriteOnce"},
+					"size": "20Gi", "annotations": map[string]any{}, "mountPath": "/var/lib/postgresql/data",
+					"extraVolumeClaimTemplates": []any{map[string]any{
+						"metadata": map[string]any{"name": "wal"},
+						"spec":     map[string]any{"resources": map[string]any{"requests": map[string]any{"storage": "5Gi"}}},
+					}},
+				},
+			},
+		},
+		{
+			name:          "cronjob",
+			manifests:     backupManifests,
+			expectedFiles: []string{"templates/cronjob.yaml"},
+			values: map[string]any{
+				"image":             map[string]any{"repository": "backup@sha256", "tag": "abcd", "pullPolicy": "IfNotPresent"},
+				"schedule":          "0 3 * * *",
+				"concurrencyPolicy": "Forbid",
+				"backoffLimit":      2,
+				"restartPolicy":     "Never",
+			},
+		},
+		{
+			name: "selector of the workload",
+			manifests: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+      tier: front
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: front
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+`,
+			expectedFiles: []string{"templates/deployment.yaml"},
+			warnings: []string{
+				"Deployment/web: selector app=web,tier=front replaced by the selector labels of the chart, " +
+					"update the objects selecting the pods and delete the Deployment before installing the chart, its selector is immutable",
+			},
+		},
+		{
+			name: "selector of the chart",
+			manifests: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: web
+      app.kubernetes.io/instance: prod
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+`,
+			expectedFiles: []string{"templates/deployment.yaml"},
+		},
+		{
+			name: "template delimiters in extra objects",
+			manifests: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: alerts
+data:
+  rules.yaml: "summary: {{ $labels.instance }} is down"
+`,
+			expectedFiles: []string{"templates/deployment.yaml", "templates/extra-manifests.yaml"},
+			values: map[string]any{
+				"extraObjects": []any{map[string]any{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]any{"name": "alerts"},
+					"data":       map[string]any{"rules.yaml": "summary: {{`{{`}} $labels.instance {{`}}`}} is down"},
+				}},
+			},
+			warnings: []string{
+				"ConfigMap/alerts: imported as is in extraObjects, its template delimiters are escaped from tpl",
+			},
+		},
+		{
+			name:        "no workload",
+			manifests:   "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+			errContains: "no Deployment, StatefulSet, DaemonSet, CronJob to import",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartDir := filepath.Join(t.TempDir(), "chart")
+			fs := filesystem.NewOSFileSystem()
+			i := NewImporter(fs, filesystem.NewDefaultTemplateProcessor(), filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+			objects, err := i.ReadManifests([]string{Stdin}, strings.NewReader(tt.manifests))
+			if err != nil {
+				t.Fatalf("ReadManifests() error = %v", err)
+			}
+
+			warnings, err := i.Import("test-chart", chartDir, objects)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Import() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("Import() warnings = %q, want %q", warnings, tt.warnings)
+			}
+			for _, file := range tt.expectedFiles {
+				if _, err := os.Stat(filepath.Join(chartDir, file)); err != nil {
+					t.Errorf("expected file %s: %v", file, err)
+				}
+			}
+
+			content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), "# -- image repository\n") {
+				t.Errorf("values.yaml lost its comments:\n%s", content)
+			}
+			var values map[string]any
+			if err := yaml.Unmarshal(content, &values); err != nil {
+				t.Fatalf("invalid values.yaml: %v\n%s", err, content)
+			}
+			for key, want := range tt.values {
+				if !reflect.DeepEqual(values[key], want) {
+					t.Errorf("values.%s = %v, want %v", key, values[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestImporter_ImportUmbrella(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "shop")
+	fs := filesystem.NewOSFileSystem()
+	i := NewImporter(fs, filesystem.NewDefaultTemplateProcessor(), filesystem.NewDefaultPathManager(), app.GetChartTemplate())
+	var charts []Chart
+	for _, manifests := range []string{webManifests, dbManifests} {
+		objects, err := i.ReadManifests([]string{Stdin}, strings.NewReader(manifests))
+		if err != nil {
+			t.Fatalf("ReadManifests() error = %v", err)
+		}
+		charts = append(charts, Chart{Name: objects[0].Name(), Objects: objects})
+	}
+	charts[0].Notes = []string{"web depends on db"}
+
+	warnings, err := i.ImportUmbrella("shop", chartDir, charts)
+	if err != nil {
+		t.Fatalf("ImportUmbrella() error = %v", err)
+	}
+	if len(warnings) == 0 || !strings.HasPrefix(warnings[0], "web: ") {
+		t.Errorf("ImportUmbrella() warnings = %q, want warnings prefixed with their component", warnings)
+	}
+
+	for _, file := range []string{"Chart.yaml", "charts/web/templates/deployment.yaml", "charts/db/templates/statefulset.yaml"} {
+		if _, err := os.Stat(filepath.Join(chartDir, file)); err != nil {
+			t.Errorf("expected file %s: %v", file, err)
+		}
+	}
+	for name, want := range map[string]string{"web": "registry.example.com/shop/web", "db": "postgres"} {
+		content, err := os.ReadFile(filepath.Join(chartDir, "charts", name, "values.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var values map[string]any
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			t.Fatalf("invalid values.yaml of %s: %v", name, err)
+		}
+		if got := lookup(values, "image", "repository"); got != want {
+			t.Errorf("%s image.repository = %v, want %v", name, got, want)
+		}
+	}
+	notes, err := os.ReadFile(filepath.Join(chartDir, "charts", "web", "templates", "NOTES.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(notes), "  * web depends on db") {
+		t.Errorf("NOTES.txt does not contain the notes of the chart:\n%s", notes)
+	}
+}
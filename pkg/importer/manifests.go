@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// Stdin is the source reading the manifests from the standard input.
+const Stdin = "-"
+
+// manifestExtensions are the extensions of the manifests read from a directory.
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// Object is a Kubernetes object decoded from a manifest.
+type Object map[string]any
+
+// Kind returns the kind of the object.
+func (o Object) Kind() string {
+	kind, _ := o["kind"].(string)
+	return kind
+}
+
+// Name returns the name of the object.
+func (o Object) Name() string {
+	name, _ := lookup(o, "metadata", "name").(string)
+	return name
+}
+
+// String returns Kind/name, the reference of the object in the warnings.
+func (o Object) String() string {
+	return o.Kind() + "/" + o.Name()
+}
+
+// ReadManifests reads the objects of the sources: manifest files, directories
+// (the .yaml, .yml and .json files they contain) or Stdin.
+func (i *Importer) ReadManifests(sources []string, stdin io.Reader) ([]Object, error) {
+	var objects []Object
+	for _, source := range sources {
+		if source == Stdin {
+			content, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, errors.NewFileSystemError("read-manifests", "failed to read the standard input", err)
+			}
+			decoded, err := DecodeManifests(content, "stdin")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, decoded...)
+			continue
+		}
+
+		err := i.fs.Walk(source, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (path != source && !hasManifestExtension(path)) {
+				return nil
+			}
+			content, err := i.fs.ReadFile(path)
+			if err != nil {
+				return errors.NewFileSystemError("read-manifests", "failed to read manifest", err).WithFile(path)
+			}
+			decoded, err := DecodeManifests(content, path)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, decoded...)
+			return nil
+		})
+		if err != nil {
+			if chartErr, ok := err.(*errors.ChartError); ok {
+				return nil, chartErr
+			}
+			return nil, errors.NewFileSystemError("read-manifests", "failed to read manifests", err).WithFile(source)
+		}
+	}
+	return objects, nil
+}
+
+func hasManifestExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range manifestExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeManifests decodes the documents of a manifest, the items of the List
+// objects written by kubectl get -o yaml are returned as separate objects.
+func DecodeManifests(content []byte, file string) ([]Object, error) {
+	var objects []Object
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for n := 1; ; n++ {
+		// nested maps are decoded with the type of the document
+		var document map[string]any
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, errors.NewValidationError("read-manifests", "invalid manifest: "+err.Error()).
+				WithFile(file).
+				WithContext("document", fmt.Sprint(n))
+		}
+		if len(document) == 0 {
+			continue
+		}
+		object := Object(document)
+		if object.Kind() == "" {
+			return nil, errors.NewValidationError("read-manifests", "the object has no kind").
+				WithFile(file).
+				WithContext("document", fmt.Sprint(n))
+		}
+		if items, ok := object["items"].([]any); ok && strings.HasSuffix(object.Kind(), "List") {
+			for _, item := range items {
+				if item, ok := item.(map[string]any); ok {
+					objects = append(objects, Object(item))
+				}
+			}
+			continue
+		}
+		objects = append(objects, object)
+	}
+}
+
+// clusterMetadata are the metadata fields managed by the cluster.
+var clusterMetadata = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp",
+	"deletionGracePeriodSeconds", "managedFields", "selfLink", "ownerReferences", "namespace",
+}
+
+// clusterAnnotations are the annotations (or prefixes of annotations) written
+// by kubectl and the controllers.
+var clusterAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"kubectl.kubernetes.io/restartedAt",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/",
+	"volume.beta.kubernetes.io/",
+	"volume.kubernetes.io/",
+	"control-plane.alpha.kubernetes.io/",
+}
+
+// stripClusterFields removes the fields managed by the cluster: the status,
+// the server side metadata, the annotations of kubectl and of the controllers,
+// the allocated IPs of the services and the bound volume of the claims. The
+// release namespace is used instead of the namespace of the object.
+func stripClusterFields(object Object) {
+	delete(object, "status")
+	if metadata, ok := object["metadata"].(map[string]any); ok {
+		for _, field := range clusterMetadata {
+			delete(metadata, field)
+		}
+		stripAnnotations(metadata, clusterAnnotations)
+	}
+	stripAnnotations(lookupMap(object, "spec", "template", "metadata"), clusterAnnotations)
+
+	spec, _ := object["spec"].(map[string]any)
+	switch object.Kind() {
+	case "Service":
+		if spec["clusterIP"] != "None" {
+			delete(spec, "clusterIP")
+			delete(spec, "clusterIPs")
+		}
+	case "PersistentVolumeClaim":
+		delete(spec, "volumeName")
+	}
+}
+
+// stripAnnotations removes the annotations starting with one of the prefixes,
+// and the annotations map when it becomes empty.
+func stripAnnotations(metadata map[string]any, prefixes []string) {
+	annotations, ok := metadata["annotations"].(map[string]any)
+	if !ok {
+		return
+	}
+	for key := range annotations {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(annotations, key)
+			}
+		}
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+// lookup returns the value at the path of nested maps, nil when a key is missing.
+func lookup(v any, path ...string) any {
+	if object, ok := v.(Object); ok {
+		v = map[string]any(object)
+	}
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// lookupMap returns the map at the path, nil when it is missing.
+func lookupMap(v any, path ...string) map[string]any {
+	m, _ := lookup(v, path...).(map[string]any)
+	return m
+}
+
+// lookupList returns the list at the path, nil when it is missing.
+func lookupList(v any, path ...string) []any {
+	l, _ := lookup(v, path...).([]any)
+	return l
+}
@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+)
+
+// value is a value imported into values.yaml at a dotted path (image.tag).
+type value struct {
+	path  string
+	value any
+}
+
+// setValues sets the values in the content of values.yaml. Only the lines of
+// the replaced values are rewritten, the comments documenting the values and
+// the layout of the file are kept. A missing key is appended to its parent.
+func setValues(content []byte, values []value) ([]byte, error) {
+	for _, v := range values {
+		var err error
+		if content, err = setValue(content, v); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+func setValue(content []byte, v value) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, errors.NewValidationError("import-values", "failed to parse values.yaml: "+err.Error()).
+			WithFile("values.yaml")
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.NewValidationError("import-values", "values.yaml is not a map").
+			WithFile("values.yaml")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	keys := strings.Split(v.path, ".")
+	parent := doc.Content[0]
+	for depth, key := range keys {
+		keyNode, valueNode := mappingEntry(parent, key)
+		if keyNode == nil {
+			// append the key after the last line of its parent
+			indent := 0
+			if depth > 0 {
+				indent = parent.Content[0].Column - 1
+			}
+			block, err := encodeEntry(key, buildValue(keys[depth+1:], v.value), indent)
+			if err != nil {
+				return nil, err
+			}
+			end := len(lines)
+			if depth > 0 {
+				end = lastLine(parent)
+			}
+			lines = append(lines[:end], append(block, lines[end:]...)...)
+			break
+		}
+		if depth < len(keys)-1 && valueNode.Kind == yaml.MappingNode && len(valueNode.Content) > 0 {
+			parent = valueNode
+			continue
+		}
+
+		// replace the lines of the entry
+		block, err := encodeEntry(key, buildValue(keys[depth+1:], v.value), keyNode.Column-1)
+		if err != nil {
+			return nil, err
+		}
+		start, end := keyNode.Line-1, max(keyNode.Line, lastLine(valueNode))
+		lines = append(lines[:start], append(block, lines[end:]...)...)
+		break
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// mappingEntry returns the key and value nodes of key in a mapping node.
+func mappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// lastLine returns the last line (1-based) of a node and of its children.
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		// the block scalar starts on the line following its indicator
+		line += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+	}
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+	return line
+}
+
+// buildValue nests the value under the remaining keys of its path.
+func buildValue(keys []string, v any) any {
+	for i := len(keys) - 1; i >= 0; i-- {
+		v = map[string]any{keys[i]: v}
+	}
+	return v
+}
+
+// encodeEntry encodes key: value as lines indented by indent spaces.
+func encodeEntry(key string, v any, indent int) ([]string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]any{key: v}); err != nil {
+		return nil, errors.NewValidationError("import-values", "failed to encode "+key+": "+err.Error()).
+			WithFile("values.yaml")
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.Repeat(" ", indent) + lines[i]
+	}
+	return lines, nil
+}
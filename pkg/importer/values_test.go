@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+const testValues = `# -- number of replicas
+replicaCount: 1
+
+image:
+  # -- image repository
+  repository: nginx
+  # -- Overrides the image tag
+  tag: ""
+
+resources: {}
+# limits:
+#   cpu: 100m
+
+ingress:
+  enabled: false
+  hosts:
+    - host: chart-example.local
+      paths:
+        - path: /
+  tls: []
+env: {}
+`
+
+func TestSetValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []value
+		expected string
+	}{
+		{
+			name:     "scalar",
+			values:   []value{{path: "replicaCount", value: 3}},
+			expected: strings.Replace(testValues, "replicaCount: 1", "replicaCount: 3", 1),
+		},
+		{
+			name:   "nested keys keep their comments",
+			values: []value{{path: "image.repository", value: "registry.local/web"}, {path: "image.tag", value: "1.0"}},
+			expected: strings.Replace(strings.Replace(testValues,
+				"repository: nginx", "repository: registry.local/web", 1),
+				`tag: ""`, "tag: \"1.0\"", 1),
+		},
+		{
+			name:   "flow map replaced by a block",
+			values: []value{{path: "resources", value: map[string]any{"limits": map[string]any{"cpu": "1"}}}},
+			expected: strings.Replace(testValues, "resources: {}\n",
+				"resources:\n  limits:\n    cpu: \"1\"\n", 1),
+		},
+		{
+			name: "block list replaced",
+			values: []value{{path: "ingress.hosts", value: []any{
+				map[string]any{"host": "web.local", "paths": []any{map[string]any{"path": "/api"}}},
+			}}},
+			expected: strings.Replace(testValues,
+				"    - host: chart-example.local\n      paths:\n        - path: /\n",
+				"    - host: web.local\n      paths:\n        - path: /api\n", 1),
+		},
+		{
+			name:     "missing nested key appended to its parent",
+			values:   []value{{path: "ingress.className", value: "nginx"}},
+			expected: strings.Replace(testValues, "  tls: []\n", "  tls: []\n  className: nginx\n", 1),
+		},
+		{
+			name:     "missing key appended to the file",
+			values:   []value{{path: "extraObjects", value: []any{}}},
+			expected: testValues + "extraObjects: []\n",
+		},
+		{
+			name:     "path below a scalar",
+			values:   []value{{path: "env.LOG_LEVEL", value: "debug"}},
+			expected: strings.Replace(testValues, "env: {}\n", "env:\n  LOG_LEVEL: debug\n", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setValues([]byte(testValues), tt.values)
+			if err != nil {
+				t.Fatalf("setValues() error = %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("setValues() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := setValues([]byte("- a\n"), []value{{path: "a", value: 1}}); err == nil ||
+		!strings.Contains(err.Error(), "values.yaml is not a map") {
+		t.Errorf("setValues() error = %v, want error containing %q", err, "values.yaml is not a map")
+	}
+}
@@ -6,7 +6,7 @@
 // (see pkg/mocks) without touching the real filesystem.
 //
 // Main Interfaces:
-//   - FileSystem: Abstracts directory creation, file read/write, and directory walking
+//   - FileSystem: Abstracts directory creation, file read/write/removal, and directory walking
 //   - File: Abstracts individual file write and close operations
 //   - TemplateProcessor: Abstracts Go template parsing and execution from embedded filesystems
 //   - PathManager: Abstracts OS-specific path join operation
@@ -29,6 +29,7 @@ type FileSystem interface {
 	ReadFile(name string) ([]byte, error)
 	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
 	Walk(root string, fn filepath.WalkFunc) error
+	Remove(name string) error
 }
 
 // File abstracts file operations.
@@ -0,0 +1,461 @@
+// Package kustomize builds a local Kustomize base or overlay offline into
+// Kubernetes objects, imported into a chart by pkg/importer.
+//
+// Build Flow of a kustomization:
+//  1. Read the resources: manifests, and directories holding a kustomization
+//     that is built first (its objects keep their transformed names)
+//  2. Generate the ConfigMaps and Secrets of configMapGenerator and
+//     secretGenerator (literals, files and env files)
+//  3. Apply the patches: patchesStrategicMerge and patches (strategic merge or
+//     JSON 6902 operations)
+//  4. Apply namePrefix/nameSuffix, commonLabels (also added to the selectors
+//     and the pod templates), commonAnnotations, replicas and images
+//  5. Update the references to the renamed objects (ConfigMap and Secret
+//     volumes and env, ServiceAccount, claims, Ingress backends, ...)
+//  6. Apply the patchesJson6902, which target the renamed objects
+//
+// The patches of an overlay target the objects by their name in the overlay
+// or in the bases. The generated ConfigMaps and Secrets keep their name,
+// without the hash suffix: the chart rolls the pods on configuration changes
+// with its checksum annotations. The namespace is left to the release and the
+// remote resources are not supported, the build reads local files only.
+package kustomize
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
+)
+
+// Files are the names of the kustomization file of a directory.
+var Files = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Kustomization is a kustomization file.
+type Kustomization struct {
+	Resources             []string          `yaml:"resources"`
+	Bases                 []string          `yaml:"bases"`
+	NamePrefix            string            `yaml:"namePrefix"`
+	NameSuffix            string            `yaml:"nameSuffix"`
+	CommonLabels          map[string]string `yaml:"commonLabels"`
+	CommonAnnotations     map[string]string `yaml:"commonAnnotations"`
+	Patches               []Patch           `yaml:"patches"`
+	PatchesStrategicMerge []string          `yaml:"patchesStrategicMerge"`
+	PatchesJSON6902       []Patch           `yaml:"patchesJson6902"`
+	ConfigMapGenerator    []Generator       `yaml:"configMapGenerator"`
+	SecretGenerator       []Generator       `yaml:"secretGenerator"`
+	GeneratorOptions      GeneratorOptions  `yaml:"generatorOptions"`
+	Images                []Image           `yaml:"images"`
+	Replicas              []Replica         `yaml:"replicas"`
+	Unsupported           map[string]any    `yaml:",inline"`
+}
+
+// Patch is a patch of the patches or patchesJson6902 fields, read from a file
+// (path) or inline (patch).
+type Patch struct {
+	Path   string  `yaml:"path"`
+	Patch  string  `yaml:"patch"`
+	Target *Target `yaml:"target"`
+}
+
+// Target selects the objects of a patch, the name is a regular expression.
+type Target struct {
+	Group              string `yaml:"group"`
+	Version            string `yaml:"version"`
+	Kind               string `yaml:"kind"`
+	Name               string `yaml:"name"`
+	Namespace          string `yaml:"namespace"`
+	LabelSelector      string `yaml:"labelSelector"`
+	AnnotationSelector string `yaml:"annotationSelector"`
+}
+
+// Generator is an entry of configMapGenerator or secretGenerator.
+type Generator struct {
+	Name     string   `yaml:"name"`
+	Behavior string   `yaml:"behavior"`
+	Type     string   `yaml:"type"`
+	Literals []string `yaml:"literals"`
+	Files    []string `yaml:"files"`
+	Envs     []string `yaml:"envs"`
+	// Env is the deprecated single env file.
+	Env     string            `yaml:"env"`
+	Options *GeneratorOptions `yaml:"options"`
+}
+
+// GeneratorOptions are the labels and annotations of the generated objects.
+type GeneratorOptions struct {
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// Image replaces the name, the tag or the digest of the images named Name.
+type Image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag"`
+	Digest  string `yaml:"digest"`
+}
+
+// Replica sets the replicas of the workload named Name.
+type Replica struct {
+	Name  string `yaml:"name"`
+	Count int    `yaml:"count"`
+}
+
+// ignoredKeys are the fields of a kustomization which need no conversion:
+// the namespace is the one of the release, and the hash suffix is not added.
+var ignoredKeys = []string{"apiVersion", "kind", "metadata", "namespace", "sortOptions", "buildMetadata"}
+
+// resource is an object of the build, with the names it had before the name
+// transformations.
+type resource struct {
+	object importer.Object
+	names  []string
+}
+
+// hasName reports whether the current or a previous name of the resource
+// matches.
+func (r *resource) hasName(match func(string) bool) bool {
+	return match(r.object.Name()) || slices.ContainsFunc(r.names, match)
+}
+
+// Builder builds kustomizations.
+type Builder struct {
+	fs interfaces.FileSystem
+}
+
+// NewBuilder creates a builder reading the kustomizations from fs.
+func NewBuilder(fs interfaces.FileSystem) *Builder {
+	return &Builder{fs: fs}
+}
+
+// Build builds the kustomization of dir. It returns its objects and the
+// warnings about the fields of the kustomizations which are not supported.
+func (b *Builder) Build(dir string) ([]importer.Object, []string, error) {
+	resources, warnings, err := b.build(dir, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects := make([]importer.Object, 0, len(resources))
+	for _, r := range resources {
+		objects = append(objects, r.object)
+	}
+	return objects, warnings, nil
+}
+
+// build builds the kustomization of dir, stack holds the directories of the
+// kustomizations including it.
+func (b *Builder) build(dir string, stack []string) ([]*resource, []string, error) {
+	dir = filepath.Clean(dir)
+	if slices.Contains(stack, dir) {
+		return nil, nil, errors.NewValidationError("build-kustomization", "the kustomization includes itself").
+			WithFile(dir)
+	}
+	stack = append(stack, dir)
+
+	k, file, err := b.load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var warnings []string
+	for _, key := range unsupportedKeys(k) {
+		warnings = append(warnings, file+": "+key+" is not supported")
+	}
+
+	var resources []*resource
+	for _, path := range append(slices.Clone(k.Resources), k.Bases...) {
+		r, w, err := b.resource(dir, path, stack)
+		if err != nil {
+			return nil, nil, errors.WrapError(err, errors.ValidationError, "build-kustomization",
+				"failed to read resource "+path).WithFile(file)
+		}
+		resources = append(resources, r...)
+		warnings = append(warnings, w...)
+	}
+
+	if resources, err = b.generate(dir, k, resources); err != nil {
+		return nil, nil, errors.WrapError(err, errors.ValidationError, "build-kustomization",
+			"failed to generate objects").WithFile(file)
+	}
+	if err := checkDuplicates(resources); err != nil {
+		return nil, nil, err.WithFile(file)
+	}
+	if resources, err = b.patch(dir, k, resources); err != nil {
+		return nil, nil, errors.WrapError(err, errors.ValidationError, "build-kustomization",
+			"failed to apply patches").WithFile(file)
+	}
+	transform(k, resources)
+	if err := b.patchJSON6902(dir, k, resources); err != nil {
+		return nil, nil, errors.WrapError(err, errors.ValidationError, "build-kustomization",
+			"failed to apply patches").WithFile(file)
+	}
+	return resources, warnings, nil
+}
+
+// load reads the kustomization file of dir.
+func (b *Builder) load(dir string) (*Kustomization, string, error) {
+	path, content, found := b.find(dir)
+	if !found {
+		return nil, "", errors.NewValidationError("build-kustomization",
+			"no kustomization found, expected one of "+strings.Join(Files, ", ")).WithFile(dir)
+	}
+	k := &Kustomization{}
+	if err := yaml.Unmarshal(content, k); err != nil {
+		return nil, "", errors.NewValidationError("build-kustomization", "invalid kustomization: "+err.Error()).
+			WithFile(path)
+	}
+	return k, path, nil
+}
+
+// find returns the path and the content of the kustomization file of dir.
+func (b *Builder) find(dir string) (string, []byte, bool) {
+	for _, name := range Files {
+		path := filepath.Join(dir, name)
+		if content, err := b.fs.ReadFile(path); err == nil {
+			return path, content, true
+		}
+	}
+	return "", nil, false
+}
+
+// unsupportedKeys returns the sorted fields of the kustomization which are not
+// built.
+func unsupportedKeys(k *Kustomization) []string {
+	var keys []string
+	for key := range k.Unsupported {
+		if !slices.Contains(ignoredKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// resource reads the objects of a resource: a manifest, or a directory whose
+// kustomization is built.
+func (b *Builder) resource(dir, path string, stack []string) ([]*resource, []string, error) {
+	if strings.Contains(path, "://") || strings.HasPrefix(path, "github.com/") || strings.HasPrefix(path, "git@") {
+		return nil, nil, errors.NewValidationError("build-kustomization",
+			"remote resources are not supported, the build reads local files only")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if _, _, found := b.find(path); found {
+		return b.build(path, stack)
+	}
+	content, err := b.fs.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.NewFileSystemError("build-kustomization",
+			"neither a manifest nor a directory with a kustomization", err).WithFile(path)
+	}
+	objects, err := importer.DecodeManifests(content, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	resources := make([]*resource, 0, len(objects))
+	for _, object := range objects {
+		resources = append(resources, &resource{object: object})
+	}
+	return resources, nil, nil
+}
+
+// checkDuplicates returns an error when two resources have the same kind,
+// namespace and name.
+func checkDuplicates(resources []*resource) *errors.ChartError {
+	seen := map[string]bool{}
+	for _, r := range resources {
+		id := r.object.String() + "." + namespace(r.object)
+		if seen[id] {
+			return errors.NewValidationError("build-kustomization", "the resource "+r.object.String()+" is declared twice")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// generate adds the ConfigMaps and the Secrets of the generators to the
+// resources, or merges their data into the existing ones of the bases
+// (behavior merge or replace).
+func (b *Builder) generate(dir string, k *Kustomization, resources []*resource) ([]*resource, error) {
+	generators := map[string][]Generator{"ConfigMap": k.ConfigMapGenerator, "Secret": k.SecretGenerator}
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		for _, g := range generators[kind] {
+			data, err := b.data(dir, g)
+			if err != nil {
+				return nil, errors.WrapError(err, errors.ValidationError, "generate", "failed to generate data").
+					WithContext("object", kind+"/"+g.Name)
+			}
+			object := importer.Object{"apiVersion": "v1", "kind": kind, "metadata": map[string]any{"name": g.Name}}
+			setData(object, data)
+			if kind == "Secret" {
+				object["type"] = valueOr(g.Type, "Opaque")
+			}
+			for _, options := range []*GeneratorOptions{&k.GeneratorOptions, g.Options} {
+				if options != nil {
+					addMetadata(object, "labels", options.Labels)
+					addMetadata(object, "annotations", options.Annotations)
+				}
+			}
+
+			switch g.Behavior {
+			case "", "create":
+				resources = append(resources, &resource{object: object})
+			case "merge", "replace":
+				i := slices.IndexFunc(resources, func(r *resource) bool {
+					return r.object.Kind() == kind && r.hasName(func(name string) bool { return name == g.Name })
+				})
+				if i < 0 {
+					return nil, errors.NewValidationError("generate", "no "+kind+"/"+g.Name+" to "+g.Behavior)
+				}
+				mergeGenerated(resources[i].object, object, g.Behavior == "replace")
+			default:
+				return nil, errors.NewValidationError("generate", "invalid behavior "+g.Behavior).
+					WithContext("object", kind+"/"+g.Name)
+			}
+		}
+	}
+	return resources, nil
+}
+
+// data returns the entries of a generator: the literals, the files and the
+// variables of the env files.
+func (b *Builder) data(dir string, g Generator) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for _, literal := range g.Literals {
+		key, value, found := strings.Cut(literal, "=")
+		if !found {
+			return nil, errors.NewValidationError("generate", "invalid literal "+literal+", expected KEY=VALUE")
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		data[key] = []byte(value)
+	}
+	for _, file := range g.Files {
+		key, path, found := strings.Cut(file, "=")
+		if !found {
+			key, path = filepath.Base(file), file
+		}
+		content, err := b.fs.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			return nil, errors.NewFileSystemError("generate", "failed to read file", err).WithFile(path)
+		}
+		data[key] = content
+	}
+	envs := g.Envs
+	if g.Env != "" {
+		envs = append(envs, g.Env)
+	}
+	for _, env := range envs {
+		content, err := b.fs.ReadFile(filepath.Join(dir, env))
+		if err != nil {
+			return nil, errors.NewFileSystemError("generate", "failed to read env file", err).WithFile(env)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, _ := strings.Cut(line, "=")
+			data[strings.TrimSpace(key)] = []byte(value)
+		}
+	}
+	return data, nil
+}
+
+// setData sets the entries of a generated object: the data of a Secret is
+// base64 encoded, the entries of a ConfigMap which are not UTF-8 are set in
+// its binaryData.
+func setData(object importer.Object, data map[string][]byte) {
+	values := map[string]any{}
+	binary := map[string]any{}
+	for key, value := range data {
+		switch {
+		case object.Kind() == "Secret":
+			values[key] = base64.StdEncoding.EncodeToString(value)
+		case !utf8.Valid(value):
+			binary[key] = base64.StdEncoding.EncodeToString(value)
+		default:
+			values[key] = string(value)
+		}
+	}
+	if len(values) > 0 {
+		object["data"] = values
+	}
+	if len(binary) > 0 {
+		object["binaryData"] = binary
+	}
+}
+
+// mergeGenerated merges the data and the metadata of a generated object into
+// the object of a base, its data is replaced when replace is set.
+func mergeGenerated(dst, generated importer.Object, replace bool) {
+	for _, field := range []string{"data", "binaryData"} {
+		data, _ := generated[field].(map[string]any)
+		existing, ok := dst[field].(map[string]any)
+		if replace || !ok {
+			if data == nil {
+				delete(dst, field)
+				continue
+			}
+			dst[field] = data
+			continue
+		}
+		for key, value := range data {
+			existing[key] = value
+		}
+	}
+	metadata, _ := generated["metadata"].(map[string]any)
+	for _, field := range []string{"labels", "annotations"} {
+		values, _ := metadata[field].(map[string]any)
+		for key, value := range values {
+			addMetadata(dst, field, map[string]string{key: fmt.Sprint(value)})
+		}
+	}
+}
+
+// addMetadata adds the labels or the annotations (field) to the metadata of
+// the object.
+func addMetadata(object map[string]any, field string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	metadata := child(object, "metadata")
+	entries := child(metadata, field)
+	for key, value := range values {
+		entries[key] = value
+	}
+}
+
+// namespace returns the namespace of the object, empty when it has none.
+func namespace(object importer.Object) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	ns, _ := metadata["namespace"].(string)
+	return ns
+}
+
+// child returns the map of m at key, created when it is missing.
+func child(m map[string]any, key string) map[string]any {
+	c, ok := m[key].(map[string]any)
+	if !ok {
+		c = map[string]any{}
+		m[key] = c
+	}
+	return c
+}
+
+func valueOr(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
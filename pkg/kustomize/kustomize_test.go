@@ -0,0 +1,477 @@
+package kustomize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+	"github.com/sgaunet/helmchart-helper/pkg/mocks"
+)
+
+// overlayFiles are a base and a prod overlay.
+var overlayFiles = map[string]string{
+	"base/kustomization.yaml": `resources:
+- deployment.yaml
+- service.yaml
+configMapGenerator:
+- name: web-config
+  literals:
+  - LOG_LEVEL=info
+  envs:
+  - app.env
+secretGenerator:
+- name: web-secret
+  literals:
+  - API_KEY=changeme
+commonLabels:
+  app: web
+`,
+	"base/app.env": "# defaults\nPORT=8080\nMODE=base\n",
+	"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      component: web
+  template:
+    metadata:
+      labels:
+        component: web
+    spec:
+      containers:
+      - name: web
+        image: ghcr.io/acme/web:1.0
+        envFrom:
+        - configMapRef:
+            name: web-config
+        env:
+        - name: API_KEY
+          valueFrom:
+            secretKeyRef:
+              name: web-secret
+              key: API_KEY
+        - name: DEBUG
+          value: "true"
+`,
+	"base/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    component: web
+  ports:
+  - port: 80
+    targetPort: http
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+  - host: web.local
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`,
+	"overlays/prod/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: prod
+namePrefix: prod-
+commonLabels:
+  env: prod
+commonAnnotations:
+  team: platform
+resources:
+- ../../base
+patchesStrategicMerge:
+- resources.yaml
+patches:
+- target:
+    kind: Ingress
+    name: web
+  patch: |-
+    - op: replace
+      path: /spec/rules/0/host
+      value: web.example.com
+- patch: |-
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+    spec:
+      template:
+        spec:
+          containers:
+          - name: web
+            env:
+            - name: DEBUG
+              $patch: delete
+patchesJson6902:
+- target:
+    group: apps
+    version: v1
+    kind: Deployment
+    name: prod-.*
+  path: args.yaml
+configMapGenerator:
+- name: web-config
+  behavior: merge
+  literals:
+  - MODE=prod
+images:
+- name: ghcr.io/acme/web
+  newTag: "2.0"
+replicas:
+- name: web
+  count: 3
+components:
+- ../../components/tracing
+`,
+	"overlays/prod/resources.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        resources:
+          limits:
+            memory: 256Mi
+`,
+	"overlays/prod/args.yaml": `- op: add
+  path: /spec/template/spec/containers/0/args
+  value: ["--verbose"]
+`,
+}
+
+// get returns the value at the path of nested maps and lists (the index of
+// the list element).
+func get(v any, path ...any) any {
+	for _, key := range path {
+		switch k := key.(type) {
+		case string:
+			m, _ := v.(map[string]any)
+			if o, ok := v.(importer.Object); ok {
+				m = o
+			}
+			v = m[k]
+		case int:
+			l, _ := v.([]any)
+			if k >= len(l) {
+				return nil
+			}
+			v = l[k]
+		}
+	}
+	return v
+}
+
+func newBuilder(files map[string]string) *Builder {
+	fs := mocks.NewMockFileSystem()
+	for name, content := range files {
+		_ = fs.WriteFile(name, []byte(content), 0644)
+	}
+	return NewBuilder(fs)
+}
+
+func TestBuilder_Build(t *testing.T) {
+	objects, warnings, err := newBuilder(overlayFiles).Build("overlays/prod")
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	var names []string
+	byName := map[string]importer.Object{}
+	for _, object := range objects {
+		names = append(names, object.String())
+		byName[object.String()] = object
+	}
+	wantNames := []string{"Deployment/prod-web", "Service/prod-web", "Ingress/prod-web", "ConfigMap/prod-web-config", "Secret/prod-web-secret"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("objects = %v, want %v", names, wantNames)
+	}
+	wantWarnings := []string{"overlays/prod/kustomization.yaml: components is not supported"}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	container := []any{"spec", "template", "spec", "containers", 0}
+	tests := []struct {
+		name   string
+		object string
+		path   []any
+		want   any
+	}{
+		{"labels", "Deployment/prod-web", []any{"metadata", "labels"}, map[string]any{"app": "web", "env": "prod"}},
+		{"selector labels", "Deployment/prod-web", []any{"spec", "selector", "matchLabels"},
+			map[string]any{"app": "web", "component": "web", "env": "prod"}},
+		{"pod annotations", "Deployment/prod-web", []any{"spec", "template", "metadata", "annotations"}, map[string]any{"team": "platform"}},
+		{"service selector", "Service/prod-web", []any{"spec", "selector"}, map[string]any{"app": "web", "component": "web", "env": "prod"}},
+		{"replicas", "Deployment/prod-web", []any{"spec", "replicas"}, 3},
+		{"image tag", "Deployment/prod-web", append(container, "image"), "ghcr.io/acme/web:2.0"},
+		{"strategic merge patch", "Deployment/prod-web", append(container, "resources", "limits", "memory"), "256Mi"},
+		{"deleted env", "Deployment/prod-web", append(container, "env"), []any{map[string]any{
+			"name": "API_KEY", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "prod-web-secret", "key": "API_KEY"}},
+		}}},
+		{"renamed configmap reference", "Deployment/prod-web", append(container, "envFrom", 0, "configMapRef", "name"), "prod-web-config"},
+		{"json patch of the target", "Deployment/prod-web", append(container, "args"), []any{"--verbose"}},
+		{"json patch", "Ingress/prod-web", []any{"spec", "rules", 0, "host"}, "web.example.com"},
+		{"renamed backend", "Ingress/prod-web", []any{"spec", "rules", 0, "http", "paths", 0, "backend", "service", "name"}, "prod-web"},
+		{"merged generator", "ConfigMap/prod-web-config", []any{"data"},
+			map[string]any{"LOG_LEVEL": "info", "PORT": "8080", "MODE": "prod"}},
+		{"secret generator", "Secret/prod-web-secret", []any{"data", "API_KEY"}, "Y2hhbmdlbWU="},
+		{"namespace of the release", "Service/prod-web", []any{"metadata", "namespace"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, ok := byName[tt.object]
+			if !ok {
+				t.Fatalf("missing object %s", tt.object)
+			}
+			if got := get(object, tt.path...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s %v = %v, want %v", tt.object, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_Build_serviceWithoutSelector(t *testing.T) {
+	objects, _, err := newBuilder(map[string]string{
+		"app/kustomization.yaml": "resources:\n- service.yaml\ncommonLabels:\n  app: db\n",
+		"app/service.yaml":       "apiVersion: v1\nkind: Service\nmetadata:\n  name: db\nspec:\n  ports:\n  - port: 5432\n",
+	}).Build("app")
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if got := get(objects[0], "metadata", "labels"); !reflect.DeepEqual(got, map[string]any{"app": "db"}) {
+		t.Errorf("labels = %v, want app=db", got)
+	}
+	if got := get(objects[0], "spec", "selector"); got != nil {
+		t.Errorf("selector = %v, want none", got)
+	}
+}
+
+func TestBuilder_Build_errors(t *testing.T) {
+	const deployment = "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"
+	tests := []struct {
+		name        string
+		files       map[string]string
+		errContains string
+	}{
+		{
+			name:        "no kustomization",
+			files:       map[string]string{"app/deployment.yaml": deployment},
+			errContains: "no kustomization found",
+		},
+		{
+			name:        "remote resource",
+			files:       map[string]string{"app/kustomization.yaml": "resources:\n- github.com/acme/base?ref=v1\n"},
+			errContains: "remote resources are not supported",
+		},
+		{
+			name:        "missing resource",
+			files:       map[string]string{"app/kustomization.yaml": "resources:\n- deployment.yaml\n"},
+			errContains: "neither a manifest nor a directory with a kustomization",
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"app/kustomization.yaml":     "resources:\n- ../base\n",
+				"base/kustomization.yaml":    "resources:\n- ../app\n",
+				"app/unused/deployment.yaml": deployment,
+			},
+			errContains: "the kustomization includes itself",
+		},
+		{
+			name: "duplicate resource",
+			files: map[string]string{
+				"app/kustomization.yaml": "resources:\n- deployment.yaml\n- copy.yaml\n",
+				"app/deployment.yaml":    deployment,
+				"app/copy.yaml":          deployment,
+			},
+			errContains: "the resource Deployment/web is declared twice",
+		},
+		{
+			name: "patch without resource",
+			files: map[string]string{
+				"app/kustomization.yaml": "resources:\n- deployment.yaml\npatchesStrategicMerge:\n- |\n  kind: Deployment\n  metadata:\n    name: api\n",
+				"app/deployment.yaml":    deployment,
+			},
+			errContains: "no resource matches the patch Deployment/api",
+		},
+		{
+			name: "json patch of a missing field",
+			files: map[string]string{
+				"app/kustomization.yaml": "resources:\n- deployment.yaml\npatches:\n- target:\n    kind: Deployment\n  patch: '[{op: replace, path: /spec/replicas, value: 2}]'\n",
+				"app/deployment.yaml":    deployment,
+			},
+			errContains: "no field spec",
+		},
+		{
+			name:        "merge without base",
+			files:       map[string]string{"app/kustomization.yaml": "configMapGenerator:\n- name: config\n  behavior: merge\n  literals: [a=b]\n"},
+			errContains: "no ConfigMap/config to merge",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := newBuilder(tt.files).Build("app")
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Build() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestStrategicMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst      map[string]any
+		patch    map[string]any
+		expected map[string]any
+	}{
+		{
+			name:     "null removes the field",
+			dst:      map[string]any{"a": 1, "b": 2},
+			patch:    map[string]any{"a": nil, "c": 3},
+			expected: map[string]any{"b": 2, "c": 3},
+		},
+		{
+			name:     "lists without merge key are replaced",
+			dst:      map[string]any{"args": []any{"a", "b"}},
+			patch:    map[string]any{"args": []any{"c"}},
+			expected: map[string]any{"args": []any{"c"}},
+		},
+		{
+			name: "container ports merged by containerPort",
+			dst: map[string]any{"ports": []any{
+				map[string]any{"containerPort": 80, "name": "http"},
+			}},
+			patch: map[string]any{"ports": []any{
+				map[string]any{"containerPort": 80, "protocol": "TCP"},
+				map[string]any{"containerPort": 9090, "name": "metrics"},
+			}},
+			expected: map[string]any{"ports": []any{
+				map[string]any{"containerPort": 80, "name": "http", "protocol": "TCP"},
+				map[string]any{"containerPort": 9090, "name": "metrics"},
+			}},
+		},
+		{
+			name:     "replaced map",
+			dst:      map[string]any{"resources": map[string]any{"limits": map[string]any{"cpu": "1"}, "requests": map[string]any{"cpu": "1"}}},
+			patch:    map[string]any{"resources": map[string]any{"$patch": "replace", "limits": map[string]any{"memory": "1Gi"}}},
+			expected: map[string]any{"resources": map[string]any{"limits": map[string]any{"memory": "1Gi"}}},
+		},
+		{
+			name: "replaced list",
+			dst:  map[string]any{"volumes": []any{map[string]any{"name": "a"}, map[string]any{"name": "b"}}},
+			patch: map[string]any{"volumes": []any{
+				map[string]any{"$patch": "replace"},
+				map[string]any{"name": "c"},
+			}},
+			expected: map[string]any{"volumes": []any{map[string]any{"name": "c"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategicMerge(tt.dst, tt.patch)
+			if !reflect.DeepEqual(tt.dst, tt.expected) {
+				t.Errorf("strategicMerge() = %v, want %v", tt.dst, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyOperation(t *testing.T) {
+	newObject := func() importer.Object {
+		return importer.Object{"spec": map[string]any{
+			"args":   []any{"a", "b"},
+			"labels": map[string]any{"app.kubernetes.io/name": "web"},
+		}}
+	}
+	tests := []struct {
+		name        string
+		op          jsonOperation
+		path        []any
+		expected    any
+		errContains string
+	}{
+		{"add to a map", jsonOperation{Op: "add", Path: "/spec/replicas", Value: 2}, []any{"spec", "replicas"}, 2, ""},
+		{"append", jsonOperation{Op: "add", Path: "/spec/args/-", Value: "c"}, []any{"spec", "args"}, []any{"a", "b", "c"}, ""},
+		{"insert", jsonOperation{Op: "add", Path: "/spec/args/0", Value: "c"}, []any{"spec", "args"}, []any{"c", "a", "b"}, ""},
+		{"remove", jsonOperation{Op: "remove", Path: "/spec/args/0"}, []any{"spec", "args"}, []any{"b"}, ""},
+		{"escaped key", jsonOperation{Op: "replace", Path: "/spec/labels/app.kubernetes.io~1name", Value: "api"},
+			[]any{"spec", "labels", "app.kubernetes.io/name"}, "api", ""},
+		{"move", jsonOperation{Op: "move", From: "/spec/args", Path: "/spec/command"}, []any{"spec"},
+			map[string]any{"command": []any{"a", "b"}, "labels": map[string]any{"app.kubernetes.io/name": "web"}}, ""},
+		{"copy", jsonOperation{Op: "copy", From: "/spec/args/1", Path: "/spec/args/-"}, []any{"spec", "args"}, []any{"a", "b", "b"}, ""},
+		{"test", jsonOperation{Op: "test", Path: "/spec/args/0", Value: "a"}, []any{"spec", "args"}, []any{"a", "b"}, ""},
+		{"failed test", jsonOperation{Op: "test", Path: "/spec/args/0", Value: "b"}, nil, nil, "test failed"},
+		{"replace a missing field", jsonOperation{Op: "replace", Path: "/spec/replicas", Value: 2}, nil, nil, "no field replicas"},
+		{"invalid index", jsonOperation{Op: "add", Path: "/spec/args/5", Value: "c"}, nil, nil, "invalid index 5"},
+		{"relative path", jsonOperation{Op: "remove", Path: "spec"}, nil, nil, "invalid path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := newObject()
+			err := applyOperation(object, tt.op)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("applyOperation() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyOperation() error = %v", err)
+			}
+			if got := get(object, tt.path...); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("%v = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatchSelector(t *testing.T) {
+	labels := map[string]any{"app": "web", "tier": "frontend"}
+	tests := []struct {
+		selector string
+		expected bool
+	}{
+		{"", true},
+		{"app=web", true},
+		{"app==web,tier=frontend", true},
+		{"app=api", false},
+		{"app!=api", true},
+		{"tier", true},
+		{"!tier", false},
+		{"canary", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := matchSelector(tt.selector, labels)
+			if err != nil {
+				t.Fatalf("matchSelector() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("matchSelector(%q) = %v, want %v", tt.selector, got, tt.expected)
+			}
+		})
+	}
+}
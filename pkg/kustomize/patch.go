@@ -0,0 +1,564 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+)
+
+// mergeKeys are the merge keys of the lists merged by a strategic merge
+// patch, the other lists are replaced. The ports of the containers are merged
+// by containerPort, the ones of the services by port.
+var mergeKeys = map[string][]string{
+	"containers":                {"name"},
+	"initContainers":            {"name"},
+	"ephemeralContainers":       {"name"},
+	"volumes":                   {"name"},
+	"env":                       {"name"},
+	"volumeMounts":              {"mountPath"},
+	"volumeDevices":             {"devicePath"},
+	"ports":                     {"containerPort", "port"},
+	"imagePullSecrets":          {"name"},
+	"hostAliases":               {"ip"},
+	"topologySpreadConstraints": {"topologyKey"},
+}
+
+// jsonOperation is an operation of a JSON 6902 patch.
+type jsonOperation struct {
+	Op    string `yaml:"op"`
+	Path  string `yaml:"path"`
+	From  string `yaml:"from"`
+	Value any    `yaml:"value"`
+}
+
+// patch applies the patchesStrategicMerge and the patches of the
+// kustomization to the resources, the resources deleted by a patch
+// ($patch: delete) are removed.
+func (b *Builder) patch(dir string, k *Kustomization, resources []*resource) ([]*resource, error) {
+	var err error
+	for _, entry := range k.PatchesStrategicMerge {
+		// an entry is a file, or an inline patch
+		content := []byte(entry)
+		if !strings.Contains(entry, "\n") {
+			if content, err = b.fs.ReadFile(filepath.Join(dir, entry)); err != nil {
+				return nil, errors.NewFileSystemError("patch", "failed to read patch", err).WithFile(entry)
+			}
+		}
+		if resources, err = strategicMergePatch(content, nil, resources); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range k.Patches {
+		content, err := b.patchContent(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		if isJSONPatch(content) {
+			err = jsonPatch(content, p.Target, resources)
+		} else {
+			resources, err = strategicMergePatch(content, p.Target, resources)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// patchJSON6902 applies the patchesJson6902 of the kustomization, after the
+// name transformations as kustomize does.
+func (b *Builder) patchJSON6902(dir string, k *Kustomization, resources []*resource) error {
+	for _, p := range k.PatchesJSON6902 {
+		content, err := b.patchContent(dir, p)
+		if err != nil {
+			return err
+		}
+		if err := jsonPatch(content, p.Target, resources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchContent returns the content of the patch, read from its file or inline.
+func (b *Builder) patchContent(dir string, p Patch) ([]byte, error) {
+	if p.Patch != "" {
+		return []byte(p.Patch), nil
+	}
+	if p.Path == "" {
+		return nil, errors.NewValidationError("patch", "the patch has neither a path nor a patch")
+	}
+	content, err := b.fs.ReadFile(filepath.Join(dir, p.Path))
+	if err != nil {
+		return nil, errors.NewFileSystemError("patch", "failed to read patch", err).WithFile(p.Path)
+	}
+	return content, nil
+}
+
+// isJSONPatch reports whether the patch is a list of JSON 6902 operations
+// rather than a strategic merge patch.
+func isJSONPatch(content []byte) bool {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil || len(node.Content) == 0 {
+		return false
+	}
+	return node.Content[0].Kind == yaml.SequenceNode
+}
+
+// strategicMergePatch applies the documents of a strategic merge patch to the
+// resources selected by the target, or to the resource with the kind and the
+// name of the document when there is no target.
+func strategicMergePatch(content []byte, target *Target, resources []*resource) ([]*resource, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document map[string]any
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, errors.NewValidationError("patch", "invalid strategic merge patch: "+err.Error())
+		}
+		if len(document) == 0 {
+			continue
+		}
+		patch := importer.Object(document)
+
+		var selected []*resource
+		if target != nil {
+			if selected, err = selectResources(target, resources); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, r := range resources {
+				if r.object.Kind() == patch.Kind() && r.hasName(func(name string) bool { return name == patch.Name() }) {
+					selected = append(selected, r)
+				}
+			}
+			if len(selected) == 0 {
+				return nil, errors.NewValidationError("patch", "no resource matches the patch "+patch.String())
+			}
+		}
+
+		if patch["$patch"] == "delete" {
+			resources = slices.DeleteFunc(resources, func(r *resource) bool { return slices.Contains(selected, r) })
+			continue
+		}
+		// the patch is applied whatever its name when it has a target
+		delete(patch, "apiVersion")
+		delete(patch, "kind")
+		if metadata, ok := patch["metadata"].(map[string]any); ok {
+			delete(metadata, "name")
+		}
+		for _, r := range selected {
+			strategicMerge(r.object, patch)
+		}
+	}
+}
+
+// strategicMerge merges the patch into dst: the maps are merged, a null
+// removes the field, the lists with a merge key are merged by key and the
+// other lists are replaced. The $patch directives replace a map or a list,
+// or delete a map or an element of a list.
+func strategicMerge(dst, patch map[string]any) {
+	if patch["$patch"] == "replace" {
+		clear(dst)
+		for key, value := range clean(patch).(map[string]any) {
+			dst[key] = value
+		}
+		return
+	}
+	for key, value := range patch {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		switch v := value.(type) {
+		case nil:
+			delete(dst, key)
+		case map[string]any:
+			if v["$patch"] == "delete" {
+				delete(dst, key)
+			} else if existing, ok := dst[key].(map[string]any); ok {
+				strategicMerge(existing, v)
+			} else {
+				dst[key] = clean(v)
+			}
+		case []any:
+			existing, _ := dst[key].([]any)
+			dst[key] = mergeList(key, existing, v)
+		default:
+			dst[key] = v
+		}
+	}
+}
+
+// mergeList merges the patch of the list field key into dst.
+func mergeList(key string, dst, patch []any) []any {
+	mergeKey := ""
+	replace := false
+	for _, item := range patch {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if m["$patch"] == "replace" {
+			replace = true
+		}
+		for _, k := range mergeKeys[key] {
+			if _, ok := m[k]; ok && mergeKey == "" {
+				mergeKey = k
+			}
+		}
+	}
+	if mergeKey == "" || replace {
+		items := make([]any, 0, len(patch))
+		for _, item := range patch {
+			if m, ok := item.(map[string]any); ok && m["$patch"] != nil {
+				continue
+			}
+			items = append(items, clean(item))
+		}
+		return items
+	}
+
+	result := slices.Clone(dst)
+	for _, item := range patch {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		i := slices.IndexFunc(result, func(e any) bool {
+			existing, ok := e.(map[string]any)
+			return ok && reflect.DeepEqual(existing[mergeKey], m[mergeKey])
+		})
+		switch {
+		case m["$patch"] == "delete":
+			if i >= 0 {
+				result = slices.Delete(result, i, i+1)
+			}
+		case i >= 0:
+			strategicMerge(result[i].(map[string]any), m)
+		default:
+			result = append(result, clean(m))
+		}
+	}
+	return result
+}
+
+// clean returns a copy of the value without the null fields and the
+// directives of the strategic merge patches.
+func clean(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			if item != nil && !strings.HasPrefix(key, "$") {
+				m[key] = clean(item)
+			}
+		}
+		return m
+	case []any:
+		l := make([]any, 0, len(v))
+		for _, item := range v {
+			l = append(l, clean(item))
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// jsonPatch applies the operations of a JSON 6902 patch to the resources
+// selected by the target.
+func jsonPatch(content []byte, target *Target, resources []*resource) error {
+	if target == nil {
+		return errors.NewValidationError("patch", "a JSON 6902 patch needs a target")
+	}
+	var operations []jsonOperation
+	if err := yaml.Unmarshal(content, &operations); err != nil {
+		return errors.NewValidationError("patch", "invalid JSON 6902 patch: "+err.Error())
+	}
+	selected, err := selectResources(target, resources)
+	if err != nil {
+		return err
+	}
+	for _, r := range selected {
+		for _, op := range operations {
+			if err := applyOperation(r.object, op); err != nil {
+				return errors.NewValidationError("patch", err.Error()).
+					WithContext("object", r.object.String()).
+					WithContext("op", op.Op+" "+op.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// applyOperation applies a JSON 6902 operation to the object.
+func applyOperation(object importer.Object, op jsonOperation) error {
+	path, err := pointer(op.Path)
+	if err != nil {
+		return err
+	}
+	root := map[string]any(object)
+	switch op.Op {
+	case "add":
+		return update(root, path, addFunc(clean(op.Value)))
+	case "remove":
+		return update(root, path, remove)
+	case "replace":
+		return update(root, path, replaceFunc(clean(op.Value)))
+	case "move", "copy":
+		from, err := pointer(op.From)
+		if err != nil {
+			return err
+		}
+		value, err := valueAt(root, from)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			if err := update(root, from, remove); err != nil {
+				return err
+			}
+		} else {
+			value = clean(value)
+		}
+		return update(root, path, addFunc(value))
+	case "test":
+		value, err := valueAt(root, path)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(value, op.Value) {
+			return fmt.Errorf("test failed, the value is %v", value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid operation %q", op.Op)
+	}
+}
+
+// pointer returns the reference tokens of a JSON pointer.
+func pointer(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid path %q, the path of a field starts with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// update applies fn to the map or the list holding the last token of the
+// path, the lists are replaced in their parent since fn may resize them.
+func update(node any, tokens []string, fn func(container any, token string) (any, error)) error {
+	_, err := updateAt(node, tokens, fn)
+	return err
+}
+
+func updateAt(node any, tokens []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		c, ok := n[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("no field %s", tokens[0])
+		}
+		updated, err := updateAt(c, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = updated
+		return n, nil
+	case []any:
+		i, err := index(tokens[0], len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := updateAt(n[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+	return nil, fmt.Errorf("%s is neither a map nor a list", tokens[0])
+}
+
+// valueAt returns the value at the path.
+func valueAt(node any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("no field %s", token)
+			}
+			node = v
+		case []any:
+			i, err := index(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("%s is neither a map nor a list", token)
+		}
+	}
+	return node, nil
+}
+
+// addFunc adds the value to a map, or inserts it in a list (- appends it).
+func addFunc(value any) func(any, string) (any, error) {
+	return func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			if token == "-" {
+				return append(c, value), nil
+			}
+			i, err := index(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(c, i, value), nil
+		}
+		return nil, fmt.Errorf("%s is not added to a value which is neither a map nor a list", token)
+	}
+}
+
+// replaceFunc replaces an existing field or element by the value.
+func replaceFunc(value any) func(any, string) (any, error) {
+	return func(container any, token string) (any, error) {
+		if _, err := valueAt(container, []string{token}); err != nil {
+			return nil, err
+		}
+		switch c := container.(type) {
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			i, _ := index(token, len(c)-1)
+			c[i] = value
+			return c, nil
+		}
+		return container, nil
+	}
+}
+
+// remove removes an existing field or element.
+func remove(container any, token string) (any, error) {
+	if _, err := valueAt(container, []string{token}); err != nil {
+		return nil, err
+	}
+	switch c := container.(type) {
+	case map[string]any:
+		delete(c, token)
+		return c, nil
+	case []any:
+		i, _ := index(token, len(c)-1)
+		return slices.Delete(c, i, i+1), nil
+	}
+	return container, nil
+}
+
+// index returns the index of a list, between 0 and last.
+func index(token string, last int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > last {
+		return 0, fmt.Errorf("invalid index %s", token)
+	}
+	return i, nil
+}
+
+// selectResources returns the resources selected by the target.
+func selectResources(target *Target, resources []*resource) ([]*resource, error) {
+	name, err := regexp.Compile("^(?:" + target.Name + ")$")
+	if err != nil {
+		return nil, errors.NewValidationError("patch", "invalid target name "+target.Name)
+	}
+	var selected []*resource
+	for _, r := range resources {
+		group, version, found := strings.Cut(fmt.Sprint(r.object["apiVersion"]), "/")
+		if !found {
+			group, version = "", group
+		}
+		switch {
+		case target.Kind != "" && r.object.Kind() != target.Kind,
+			target.Group != "" && group != target.Group,
+			target.Version != "" && version != target.Version,
+			target.Namespace != "" && namespace(r.object) != target.Namespace,
+			target.Name != "" && !r.hasName(name.MatchString):
+			continue
+		}
+		metadata, _ := r.object["metadata"].(map[string]any)
+		labels, _ := metadata["labels"].(map[string]any)
+		annotations, _ := metadata["annotations"].(map[string]any)
+		matchLabels, err := matchSelector(target.LabelSelector, labels)
+		if err != nil {
+			return nil, err
+		}
+		matchAnnotations, err := matchSelector(target.AnnotationSelector, annotations)
+		if err != nil {
+			return nil, err
+		}
+		if matchLabels && matchAnnotations {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
+
+// matchSelector reports whether the labels match the equality based selector
+// (key=value, key!=value, key and !key requirements).
+func matchSelector(selector string, labels map[string]any) (bool, error) {
+	if selector == "" {
+		return true, nil
+	}
+	if strings.Contains(selector, "(") {
+		return false, errors.NewValidationError("patch", "set based selector "+selector+" is not supported")
+	}
+	for _, requirement := range strings.Split(selector, ",") {
+		requirement = strings.TrimSpace(requirement)
+		if key, value, found := strings.Cut(requirement, "!="); found {
+			if v, ok := labels[strings.TrimSpace(key)]; ok && fmt.Sprint(v) == strings.TrimSpace(value) {
+				return false, nil
+			}
+			continue
+		}
+		if key, value, found := strings.Cut(requirement, "="); found {
+			v, ok := labels[strings.TrimSpace(key)]
+			if !ok || fmt.Sprint(v) != strings.TrimSpace(strings.TrimPrefix(value, "=")) {
+				return false, nil
+			}
+			continue
+		}
+		if key, found := strings.CutPrefix(requirement, "!"); found {
+			if _, ok := labels[key]; ok {
+				return false, nil
+			}
+			continue
+		}
+		if _, ok := labels[requirement]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
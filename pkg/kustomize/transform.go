@@ -0,0 +1,253 @@
+package kustomize
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/helmchart-helper/pkg/importer"
+)
+
+// podTemplates are the paths of the pod templates of the workloads.
+var podTemplates = map[string][]string{
+	"Deployment":  {"spec", "template"},
+	"StatefulSet": {"spec", "template"},
+	"DaemonSet":   {"spec", "template"},
+	"ReplicaSet":  {"spec", "template"},
+	"Job":         {"spec", "template"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template"},
+}
+
+// selectors are the paths of the label selectors completed with the
+// commonLabels.
+var selectors = map[string][]string{
+	"Deployment":          {"spec", "selector", "matchLabels"},
+	"StatefulSet":         {"spec", "selector", "matchLabels"},
+	"DaemonSet":           {"spec", "selector", "matchLabels"},
+	"ReplicaSet":          {"spec", "selector", "matchLabels"},
+	"Service":             {"spec", "selector"},
+	"PodDisruptionBudget": {"spec", "selector", "matchLabels"},
+}
+
+// scaledKinds are the kinds whose replicas are set by the replicas field.
+var scaledKinds = []string{"Deployment", "StatefulSet", "ReplicaSet"}
+
+// unprefixedKinds are the kinds whose name is kept by namePrefix and
+// nameSuffix.
+var unprefixedKinds = []string{"CustomResourceDefinition", "Namespace"}
+
+// transform applies namePrefix, nameSuffix, commonLabels, commonAnnotations,
+// replicas and images to the resources, and updates the references to the
+// renamed objects.
+func transform(k *Kustomization, resources []*resource) {
+	// renamed holds the new names of the renamed objects per kind
+	renamed := map[string]map[string]string{}
+	for _, r := range resources {
+		kind := r.object.Kind()
+		if (k.NamePrefix != "" || k.NameSuffix != "") && !slices.Contains(unprefixedKinds, kind) {
+			name := r.object.Name()
+			child(r.object, "metadata")["name"] = k.NamePrefix + name + k.NameSuffix
+			r.names = append(r.names, name)
+			if renamed[kind] == nil {
+				renamed[kind] = map[string]string{}
+			}
+			renamed[kind][name] = r.object.Name()
+		}
+
+		addMetadata(r.object, "labels", k.CommonLabels)
+		addMetadata(r.object, "annotations", k.CommonAnnotations)
+		// a Service without selector has its endpoints managed outside of the
+		// cluster, its selector is only extended when it exists
+		if path, ok := selectors[kind]; ok && len(k.CommonLabels) > 0 &&
+			(kind != "Service" || lookupMap(r.object, path...) != nil) {
+			selector := mapAt(r.object, path...)
+			for key, value := range k.CommonLabels {
+				selector[key] = value
+			}
+		}
+		if path, ok := podTemplates[kind]; ok {
+			template := mapAt(r.object, path...)
+			addMetadata(template, "labels", k.CommonLabels)
+			addMetadata(template, "annotations", k.CommonAnnotations)
+			if kind == "CronJob" {
+				jobTemplate := mapAt(r.object, "spec", "jobTemplate")
+				addMetadata(jobTemplate, "labels", k.CommonLabels)
+				addMetadata(jobTemplate, "annotations", k.CommonAnnotations)
+			}
+		}
+
+		for _, replica := range k.Replicas {
+			if slices.Contains(scaledKinds, kind) && r.hasName(func(name string) bool { return name == replica.Name }) {
+				child(r.object, "spec")["replicas"] = replica.Count
+			}
+		}
+		if pod := podSpec(r.object); pod != nil && len(k.Images) > 0 {
+			for _, field := range []string{"initContainers", "containers"} {
+				for _, container := range maps(pod[field]) {
+					setImage(container, k.Images)
+				}
+			}
+		}
+	}
+
+	if len(renamed) > 0 {
+		for _, r := range resources {
+			updateReferences(r.object, renamed)
+		}
+	}
+}
+
+// setImage replaces the name, the tag or the digest of the image of the
+// container when its name is the name of one of the images.
+func setImage(container map[string]any, images []Image) {
+	image, _ := container["image"].(string)
+	name, tag := splitImage(image)
+	for _, i := range images {
+		if i.Name != name {
+			continue
+		}
+		name = valueOr(i.NewName, name)
+		switch {
+		case i.Digest != "":
+			tag = "@" + i.Digest
+		case i.NewTag != "":
+			tag = ":" + i.NewTag
+		}
+		container["image"] = name + tag
+		return
+	}
+}
+
+// splitImage returns the name of an image and its tag (:tag) or digest
+// (@digest).
+func splitImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i:]
+	}
+	return image, ""
+}
+
+// updateReferences renames the references of the object to the renamed
+// objects: the ConfigMaps, Secrets, ServiceAccount and claims of the pods,
+// the services and TLS secrets of the ingresses, the target of the
+// autoscalers and the roles and subjects of the role bindings.
+func updateReferences(object importer.Object, renamed map[string]map[string]string) {
+	rename := func(kind string, m map[string]any, key string) {
+		if name, ok := m[key].(string); ok {
+			if newName, ok := renamed[kind][name]; ok {
+				m[key] = newName
+			}
+		}
+	}
+
+	if pod := podSpec(object); pod != nil {
+		rename("ServiceAccount", pod, "serviceAccountName")
+		for _, secret := range maps(pod["imagePullSecrets"]) {
+			rename("Secret", secret, "name")
+		}
+		for _, volume := range maps(pod["volumes"]) {
+			rename("ConfigMap", lookupMap(volume, "configMap"), "name")
+			rename("Secret", lookupMap(volume, "secret"), "secretName")
+			rename("PersistentVolumeClaim", lookupMap(volume, "persistentVolumeClaim"), "claimName")
+			for _, source := range maps(lookup(volume, "projected", "sources")) {
+				rename("ConfigMap", lookupMap(source, "configMap"), "name")
+				rename("Secret", lookupMap(source, "secret"), "name")
+			}
+		}
+		for _, field := range []string{"initContainers", "containers"} {
+			for _, container := range maps(pod[field]) {
+				for _, env := range maps(container["env"]) {
+					rename("ConfigMap", lookupMap(env, "valueFrom", "configMapKeyRef"), "name")
+					rename("Secret", lookupMap(env, "valueFrom", "secretKeyRef"), "name")
+				}
+				for _, from := range maps(container["envFrom"]) {
+					rename("ConfigMap", lookupMap(from, "configMapRef"), "name")
+					rename("Secret", lookupMap(from, "secretRef"), "name")
+				}
+			}
+		}
+	}
+
+	spec := lookupMap(object, "spec")
+	switch object.Kind() {
+	case "StatefulSet":
+		rename("Service", spec, "serviceName")
+	case "Ingress":
+		rename("Service", lookupMap(spec, "defaultBackend", "service"), "name")
+		for _, rule := range maps(spec["rules"]) {
+			for _, path := range maps(lookup(rule, "http", "paths")) {
+				rename("Service", lookupMap(path, "backend", "service"), "name")
+			}
+		}
+		for _, tls := range maps(spec["tls"]) {
+			rename("Secret", tls, "secretName")
+		}
+	case "HorizontalPodAutoscaler":
+		target := lookupMap(spec, "scaleTargetRef")
+		kind, _ := target["kind"].(string)
+		rename(kind, target, "name")
+	case "RoleBinding", "ClusterRoleBinding":
+		for _, ref := range append(maps(object["subjects"]), lookupMap(object, "roleRef")) {
+			kind, _ := ref["kind"].(string)
+			rename(kind, ref, "name")
+		}
+	}
+}
+
+// podSpec returns the pod spec of a pod or of a workload, nil for the other
+// objects.
+func podSpec(object importer.Object) map[string]any {
+	if object.Kind() == "Pod" {
+		return lookupMap(object, "spec")
+	}
+	path, ok := podTemplates[object.Kind()]
+	if !ok {
+		return nil
+	}
+	return lookupMap(object, append(slices.Clone(path), "spec")...)
+}
+
+// mapAt returns the map at the path of nested maps, the missing maps are
+// created.
+func mapAt(m map[string]any, path ...string) map[string]any {
+	for _, key := range path {
+		m = child(m, key)
+	}
+	return m
+}
+
+// lookup returns the value at the path of nested maps, nil when a key is
+// missing.
+func lookup(v any, path ...string) any {
+	if object, ok := v.(importer.Object); ok {
+		v = map[string]any(object)
+	}
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// lookupMap returns the map at the path, nil when it is missing.
+func lookupMap(v any, path ...string) map[string]any {
+	m, _ := lookup(v, path...).(map[string]any)
+	return m
+}
+
+// maps returns the maps of a list.
+func maps(v any) []map[string]any {
+	list, _ := v.([]any)
+	result := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+// Package lint checks generated Helm charts.
+//
+// Checks:
+//   - Security profile: the security contexts defined in values.yaml (main
+//     container, initContainers, sidecars and extraContainers) satisfy the Pod
+//     Security Standard of the chart. The profile is read from the
+//     helmchart-helper/security-profile annotation of Chart.yaml, written by
+//     the generator, unless it is given explicitly.
+//   - Hardening: the settings written by the generator for the restricted
+//     profile that the Pod Security Standard does not require (read-only root
+//     filesystem) are reported as warnings.
+//
+// Error Handling:
+//   - Unreadable or invalid Chart.yaml/values.yaml return a FileSystemError or
+//     a ValidationError with the file context
+//   - Violations are not errors, they are returned as findings, hardening
+//     recommendations are findings flagged as warnings
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
+)
+
+// SecurityProfileAnnotation is the Chart.yaml annotation recording the
+// security profile selected at generation time.
+const SecurityProfileAnnotation = "helmchart-helper/security-profile"
+
+// baselineCapabilities are the capabilities the baseline Pod Security Standard
+// allows to add.
+var baselineCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// extraContainerLists are the values lists holding additional containers.
+var extraContainerLists = []string{"initContainers", "sidecars", "extraContainers"}
+
+// Finding is a violation reported by the linter. Warnings report hardening
+// recommendations that are not required by the Pod Security Standard.
+type Finding struct {
+	File    string
+	Path    string
+	Message string
+	Warning bool
+}
+
+// String formats the finding as file: path: message, warnings are prefixed
+// with warning.
+func (f Finding) String() string {
+	if f.Warning {
+		return fmt.Sprintf("warning: %s: %s: %s", f.File, f.Path, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.File, f.Path, f.Message)
+}
+
+// Linter checks the charts found on a filesystem.
+type Linter struct {
+	fs          interfaces.FileSystem
+	pathManager interfaces.PathManager
+}
+
+// NewLinter creates a linter reading the charts from fs.
+func NewLinter(fs interfaces.FileSystem, pathManager interfaces.PathManager) *Linter {
+	return &Linter{fs: fs, pathManager: pathManager}
+}
+
+// Lint checks the chart in chartDir. When profile is empty, the profile
+// recorded in Chart.yaml is used, charts without profile are not checked.
+func (l *Linter) Lint(chartDir, profile string) ([]Finding, error) {
+	if profile == "" {
+		chart, err := l.readYAML(chartDir, "Chart.yaml")
+		if err != nil {
+			return nil, err
+		}
+		annotations, _ := chart["annotations"].(map[string]any)
+		profile, _ = annotations[SecurityProfileAnnotation].(string)
+	}
+	if profile == "" || profile == app.SecurityNone {
+		return nil, nil
+	}
+	if !slices.Contains(app.SecurityProfiles, profile) {
+		return nil, errors.NewValidationError("lint",
+			"security profile must be one of "+strings.Join(app.SecurityProfiles, ", ")).
+			WithContext("value", profile)
+	}
+
+	values, err := l.readYAML(chartDir, "values.yaml")
+	if err != nil {
+		return nil, err
+	}
+	return checkSecurity(values, profile), nil
+}
+
+// readYAML reads and decodes a YAML file of the chart.
+func (l *Linter) readYAML(chartDir, name string) (map[string]any, error) {
+	path := l.pathManager.Join(chartDir, name)
+	content, err := l.fs.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewFileSystemError("lint", "failed to read "+name, err).
+			WithFile(path)
+	}
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, errors.WrapError(err, errors.ValidationError, "lint", "failed to parse "+name).
+			WithFile(path)
+	}
+	return doc, nil
+}
+
+// checkSecurity checks every container security context of the values.
+func checkSecurity(values map[string]any, profile string) []Finding {
+	pod := mapAt(values, "podSecurityContext")
+	var findings []Finding
+	report := func(path, msg string) {
+		findings = append(findings, Finding{File: "values.yaml", Path: path, Message: msg})
+	}
+
+	checkPod(pod, profile, report)
+	checkContainer("securityContext", mapAt(values, "securityContext"), pod, profile, report)
+
+	for _, list := range extraContainerLists {
+		containers, _ := values[list].([]any)
+		for i, c := range containers {
+			container, _ := c.(map[string]any)
+			path := fmt.Sprintf("%s[%d].securityContext", list, i)
+			checkContainer(path, mapAt(container, "securityContext"), pod, profile, report)
+		}
+	}
+	return append(findings, checkHardening(values, profile)...)
+}
+
+// checkHardening reports the hardening settings written by the generator that
+// are not part of the Pod Security Standard as warnings.
+func checkHardening(values map[string]any, profile string) []Finding {
+	if profile != app.SecurityRestricted || mapAt(values, "securityContext")["readOnlyRootFilesystem"] == true {
+		return nil
+	}
+	return []Finding{{
+		File:    "values.yaml",
+		Path:    "securityContext.readOnlyRootFilesystem",
+		Message: "should be true (mount an emptyDir for the writable paths)",
+		Warning: true,
+	}}
+}
+
+// checkPod checks the pod security context.
+func checkPod(pod map[string]any, profile string, report func(path, msg string)) {
+	if seccompType(pod) == "Unconfined" {
+		report("podSecurityContext.seccompProfile.type", "Unconfined is not allowed")
+	}
+	if profile == app.SecurityRestricted && pod["runAsUser"] == 0 {
+		report("podSecurityContext.runAsUser", "must not be 0")
+	}
+}
+
+// checkContainer checks a container security context, pod holds the pod
+// security context whose settings are inherited by the container.
+func checkContainer(path string, ctx, pod map[string]any, profile string, report func(path, msg string)) {
+	if ctx["privileged"] == true {
+		report(path+".privileged", "privileged containers are not allowed")
+	}
+	if seccompType(ctx) == "Unconfined" {
+		report(path+".seccompProfile.type", "Unconfined is not allowed")
+	}
+	capabilities := mapAt(ctx, "capabilities")
+	allowed := baselineCapabilities
+	if profile == app.SecurityRestricted {
+		allowed = []string{"NET_BIND_SERVICE"}
+	}
+	for _, c := range stringList(capabilities["add"]) {
+		if !slices.Contains(allowed, c) {
+			report(path+".capabilities.add", fmt.Sprintf("capability %s is not allowed", c))
+		}
+	}
+
+	if profile != app.SecurityRestricted {
+		return
+	}
+	if ctx["allowPrivilegeEscalation"] != false {
+		report(path+".allowPrivilegeEscalation", "must be false")
+	}
+	if !slices.Contains(stringList(capabilities["drop"]), "ALL") {
+		report(path+".capabilities.drop", "must contain ALL")
+	}
+	if ctx["runAsNonRoot"] != true && (ctx["runAsNonRoot"] != nil || pod["runAsNonRoot"] != true) {
+		report(path+".runAsNonRoot", "must be true (or set in podSecurityContext)")
+	}
+	if ctx["runAsUser"] == 0 {
+		report(path+".runAsUser", "must not be 0")
+	}
+	if t := seccompType(ctx); t != "RuntimeDefault" && t != "Localhost" {
+		if t != "" || (seccompType(pod) != "RuntimeDefault" && seccompType(pod) != "Localhost") {
+			report(path+".seccompProfile.type", "must be RuntimeDefault or Localhost (or set in podSecurityContext)")
+		}
+	}
+}
+
+// mapAt returns the map stored under key, or an empty map.
+func mapAt(m map[string]any, key string) map[string]any {
+	if v, ok := m[key].(map[string]any); ok {
+		return v
+	}
+	return map[string]any{}
+}
+
+// seccompType returns the seccompProfile type of a security context.
+func seccompType(ctx map[string]any) string {
+	t, _ := mapAt(ctx, "seccompProfile")["type"].(string)
+	return t
+}
+
+// stringList converts a YAML sequence of strings.
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
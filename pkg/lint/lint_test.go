@@ -0,0 +1,178 @@
+package lint
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/app"
+	"github.com/sgaunet/helmchart-helper/pkg/filesystem"
+	"github.com/sgaunet/helmchart-helper/pkg/mocks"
+)
+
+const restrictedValues = `
+podSecurityContext:
+  runAsNonRoot: true
+  runAsUser: 1000
+  seccompProfile:
+    type: RuntimeDefault
+securityContext:
+  allowPrivilegeEscalation: false
+  privileged: false
+  readOnlyRootFilesystem: true
+  runAsNonRoot: true
+  capabilities:
+    drop:
+    - ALL
+sidecars:
+- name: log
+  securityContext:
+    allowPrivilegeEscalation: false
+    capabilities:
+      drop:
+      - ALL
+`
+
+func TestLinter_Lint(t *testing.T) {
+	tests := []struct {
+		name        string
+		chart       string
+		values      string
+		profile     string
+		expected    []string
+		errContains string
+	}{
+		{
+			name:   "no profile",
+			chart:  "name: test\n",
+			values: "securityContext:\n  privileged: true\n",
+		},
+		{
+			name:   "profile from chart annotation",
+			chart:  "name: test\nannotations:\n  helmchart-helper/security-profile: baseline\n",
+			values: "securityContext:\n  privileged: true\n",
+			expected: []string{
+				"values.yaml: securityContext.privileged: privileged containers are not allowed",
+			},
+		},
+		{
+			name:    "restricted values",
+			values:  restrictedValues,
+			profile: "restricted",
+		},
+		{
+			name:    "baseline capabilities",
+			values:  "securityContext:\n  capabilities:\n    add:\n    - CHOWN\n    - SYS_ADMIN\n",
+			profile: "baseline",
+			expected: []string{
+				"values.yaml: securityContext.capabilities.add: capability SYS_ADMIN is not allowed",
+			},
+		},
+		{
+			name: "baseline unconfined seccomp",
+			values: "podSecurityContext:\n  seccompProfile:\n    type: Unconfined\n" +
+				"initContainers:\n- name: init\n  securityContext:\n    privileged: true\n",
+			profile: "baseline",
+			expected: []string{
+				"values.yaml: podSecurityContext.seccompProfile.type: Unconfined is not allowed",
+				"values.yaml: initContainers[0].securityContext.privileged: privileged containers are not allowed",
+			},
+		},
+		{
+			name:    "restricted empty values",
+			values:  "securityContext: {}\n",
+			profile: "restricted",
+			expected: []string{
+				"values.yaml: securityContext.allowPrivilegeEscalation: must be false",
+				"values.yaml: securityContext.capabilities.drop: must contain ALL",
+				"values.yaml: securityContext.runAsNonRoot: must be true (or set in podSecurityContext)",
+				"values.yaml: securityContext.seccompProfile.type: must be RuntimeDefault or Localhost (or set in podSecurityContext)",
+				"warning: values.yaml: securityContext.readOnlyRootFilesystem: should be true (mount an emptyDir for the writable paths)",
+			},
+		},
+		{
+			name:    "restricted root user in extra container",
+			values:  strings.Replace(restrictedValues, "sidecars:", "extraContainers:", 1) + "    runAsUser: 0\n",
+			profile: "restricted",
+			expected: []string{
+				"values.yaml: extraContainers[0].securityContext.runAsUser: must not be 0",
+			},
+		},
+		{
+			name:        "unknown profile",
+			values:      restrictedValues,
+			profile:     "privileged",
+			errContains: "security profile must be one of",
+		},
+		{
+			name:        "invalid values",
+			values:      "securityContext: [",
+			profile:     "baseline",
+			errContains: "failed to parse values.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := mocks.NewMockFileSystem()
+			fs.Files["chart/Chart.yaml"] = []byte(tt.chart)
+			fs.Files["chart/values.yaml"] = []byte(tt.values)
+			linter := NewLinter(fs, mocks.NewMockPathManager())
+
+			findings, err := linter.Lint("chart", tt.profile)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Lint() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lint() error = %v", err)
+			}
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.String())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Lint() findings = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLinter_Lint_missingChart(t *testing.T) {
+	linter := NewLinter(mocks.NewMockFileSystem(), mocks.NewMockPathManager())
+	if _, err := linter.Lint("chart", ""); err == nil || !strings.Contains(err.Error(), "failed to read Chart.yaml") {
+		t.Errorf("Lint() error = %v, want error containing %q", err, "failed to read Chart.yaml")
+	}
+}
+
+// TestLinter_Lint_generatedCharts checks that the generated charts satisfy
+// their own security profile.
+func TestLinter_Lint_generatedCharts(t *testing.T) {
+	fs := filesystem.NewOSFileSystem()
+	pathManager := filesystem.NewDefaultPathManager()
+
+	for _, profile := range app.SecurityProfiles {
+		t.Run(profile, func(t *testing.T) {
+			chartDir := filepath.Join(t.TempDir(), "chart")
+			chart := app.NewApp("test-chart", chartDir, fs, filesystem.NewDefaultTemplateProcessor(), pathManager, app.GetChartTemplate())
+			chart.SetDeployment(true)
+			chart.SetService(true)
+			chart.SetSidecars([]app.Sidecar{{Name: "log", Image: "busybox"}})
+			chart.SetSecurityProfile(profile)
+			if err := chart.GenerateChart(); err != nil {
+				t.Fatalf("GenerateChart() error = %v", err)
+			}
+
+			findings, err := NewLinter(fs, pathManager).Lint(chartDir, "")
+			if err != nil {
+				t.Fatalf("Lint() error = %v", err)
+			}
+			if len(findings) != 0 {
+				t.Errorf("Lint() findings = %v, want none", findings)
+			}
+		})
+	}
+}
@@ -95,6 +95,18 @@ func (mfs *MockFileSystem) OpenFile(name string, _ int, _ fs.FileMode) (interfac
 	return &MockFile{name: name, fs: mfs}, nil
 }
 
+// Remove simulates removing a file from the mock filesystem.
+func (mfs *MockFileSystem) Remove(name string) error {
+	if err, exists := mfs.Errors["Remove:"+name]; exists {
+		return err
+	}
+	if _, exists := mfs.Files[name]; !exists {
+		return ErrFileNotFound
+	}
+	delete(mfs.Files, name)
+	return nil
+}
+
 // Walk simulates walking the directory tree in the mock filesystem.
 func (mfs *MockFileSystem) Walk(root string, fn filepath.WalkFunc) error {
 	if err, exists := mfs.Errors["Walk:"+root]; exists {
@@ -0,0 +1,123 @@
+package upgrade
+
+import (
+	"slices"
+	"strings"
+)
+
+// Merge3 merges line by line the changes from base to local and from base to
+// other, as diff3 does. The conflicting changes are written between conflict
+// markers named after localLabel and otherLabel, it reports whether there are
+// conflicts.
+func Merge3(base, local, other, localLabel, otherLabel string) (string, bool) {
+	baseLines, localLines, otherLines := lines(base), lines(local), lines(other)
+	toLocal := matches(baseLines, localLines)
+	toOther := matches(baseLines, otherLines)
+
+	var merged strings.Builder
+	conflict := false
+	write := func(chunk []string) {
+		for _, line := range chunk {
+			merged.WriteString(line)
+		}
+	}
+
+	i, l, o := 0, 0, 0
+	for i < len(baseLines) || l < len(localLines) || o < len(otherLines) {
+		// a line of the base kept by both sides
+		if i < len(baseLines) && toLocal[i] == l && toOther[i] == o {
+			merged.WriteString(baseLines[i])
+			i, l, o = i+1, l+1, o+1
+			continue
+		}
+
+		// the chunks changed until the next line kept by both sides
+		next := i
+		for next < len(baseLines) && (toLocal[next] < 0 || toOther[next] < 0) {
+			next++
+		}
+		localEnd, otherEnd := len(localLines), len(otherLines)
+		if next < len(baseLines) {
+			localEnd, otherEnd = toLocal[next], toOther[next]
+		}
+		baseChunk, localChunk, otherChunk := baseLines[i:next], localLines[l:localEnd], otherLines[o:otherEnd]
+
+		switch {
+		case slices.Equal(localChunk, baseChunk):
+			write(otherChunk)
+		case slices.Equal(otherChunk, baseChunk), slices.Equal(localChunk, otherChunk):
+			write(localChunk)
+		default:
+			conflict = true
+			merged.WriteString("<<<<<<< " + localLabel + "\n")
+			write(localChunk)
+			merged.WriteString("=======\n")
+			write(otherChunk)
+			merged.WriteString(">>>>>>> " + otherLabel + "\n")
+		}
+		i, l, o = next, localEnd, otherEnd
+	}
+
+	result := merged.String()
+	// the line break at the end of the file is the one of other when it
+	// changed it, of local otherwise
+	lineBreak := endsWithLineBreak(local)
+	if endsWithLineBreak(other) != endsWithLineBreak(base) {
+		lineBreak = endsWithLineBreak(other)
+	}
+	if !conflict && !lineBreak {
+		result = strings.TrimSuffix(result, "\n")
+	}
+	return result, conflict
+}
+
+// lines splits the content in lines ending with a line break, the last line
+// gets one when it has none.
+func lines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	if !endsWithLineBreak(content) {
+		content += "\n"
+	}
+	return strings.SplitAfter(content, "\n")[:strings.Count(content, "\n")]
+}
+
+func endsWithLineBreak(content string) bool {
+	return content == "" || strings.HasSuffix(content, "\n")
+}
+
+// matches returns, for each line of a, the index of the line of b it is
+// matched with in a longest common subsequence of the lines, -1 when it is
+// not matched.
+func matches(a, b []string) []int {
+	// lcs[i*(len(b)+1)+j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	width := len(b) + 1
+	lcs := make([]int, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	result := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			result[i] = j
+			i, j = i+1, j+1
+		case j < len(b) && lcs[(i+1)*width+j] < lcs[i*width+j+1]:
+			j++
+		default:
+			result[i] = -1
+			i++
+		}
+	}
+	return result
+}
@@ -0,0 +1,328 @@
+// Package upgrade re-applies the templates of a newer version of the
+// generator to a chart generated by an older one, keeping the changes made to
+// the chart since its generation.
+//
+// Upgrade Flow:
+//  1. The generator records its version and its arguments in the lock file
+//     (.helmchart-helper.yaml) of the chart
+//  2. The base, the chart as the recorded version generated it, and the new
+//     chart, generated by the current version, are regenerated from the
+//     recorded arguments in scratch directories
+//  3. Each file of the chart is three-way merged: the changes from the base
+//     to the new chart are applied to the file of the chart, the conflicting
+//     changes are written between conflict markers
+//  4. The version of the lock file is updated
+//
+// The files of the chart which are unchanged since the generation are
+// replaced or removed, the files deleted from the chart stay deleted.
+package upgrade
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgaunet/helmchart-helper/pkg/errors"
+	"github.com/sgaunet/helmchart-helper/pkg/interfaces"
+)
+
+// LockFile is the name of the lock file in the chart directory.
+const LockFile = ".helmchart-helper.yaml"
+
+// lockHeader is written at the top of the lock file.
+const lockHeader = `# Generated by helmchart-helper: the version and the arguments of the
+# generation of the chart, helmchart-helper upgrade regenerates the chart from
+# them to re-apply newer templates.
+`
+
+// Lock is the content of the lock file.
+type Lock struct {
+	// Version is the version of the generator.
+	Version string `yaml:"version"`
+	// Command is the command of the generator, empty for a chart and
+	// "umbrella" for an umbrella chart.
+	Command string `yaml:"command,omitempty"`
+	// Args are the arguments of the command, without the path of the chart.
+	Args []string `yaml:"args"`
+}
+
+// PathFlags are the flags of the generator (and options of the umbrella
+// components) whose value is a path, they are recorded relative to the chart.
+var PathFlags = []string{"config-from-file", "config-from-dir", "env-profile", "from-dockerfile"}
+
+// NewLock returns the lock of the chart generated in chartPath by the command
+// with the arguments. The -o flag (the path of the chart) is not recorded, and
+// the paths of the PathFlags are made relative to the chart so that the chart
+// is upgraded from any directory.
+func NewLock(version, command, chartPath string, args []string) (Lock, error) {
+	lock := Lock{Version: version, Command: command, Args: []string{}}
+	chart, err := filepath.Abs(chartPath)
+	if err != nil {
+		return lock, errors.NewFileSystemError("lock", "failed to resolve the chart path", err).WithFile(chartPath)
+	}
+	var pathErr error
+	relative := func(path string) string {
+		abs, err := filepath.Abs(path)
+		rel := ""
+		if err == nil {
+			rel, err = filepath.Rel(chart, abs)
+		}
+		if err != nil {
+			if pathErr == nil {
+				pathErr = errors.NewFileSystemError("lock", "failed to record the path relative to the chart", err).
+					WithFile(path)
+			}
+			return path
+		}
+		return filepath.ToSlash(rel)
+	}
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-o" || arg == "--o":
+			i++
+		case strings.HasPrefix(arg, "-o=") || strings.HasPrefix(arg, "--o="):
+		default:
+			lock.Args = append(lock.Args, arg)
+		}
+	}
+	lock.Args = mapPaths(lock.Args, relative)
+	return lock, pathErr
+}
+
+// ResolveArgs returns the arguments of the lock of the chart in chartPath, the
+// paths of the PathFlags are resolved from the chart.
+func (l Lock) ResolveArgs(chartPath string) []string {
+	return mapPaths(l.Args, func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		if abs, err := filepath.Abs(filepath.Join(chartPath, filepath.FromSlash(path))); err == nil {
+			return abs
+		}
+		return filepath.Join(chartPath, filepath.FromSlash(path))
+	})
+}
+
+// mapPaths returns the arguments with the paths of the PathFlags replaced by
+// fn, given as -flag path, -flag=path or as option of -component
+// name=option,flag=path.
+func mapPaths(args []string, fn func(string) string) []string {
+	mapped := slices.Clone(args)
+	for i := 0; i < len(mapped); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(mapped[i], "-"), "=")
+		if !strings.HasPrefix(mapped[i], "-") {
+			continue
+		}
+		switch {
+		case slices.Contains(PathFlags, name) && hasValue:
+			mapped[i] = mapped[i][:len(mapped[i])-len(value)] + fn(value)
+		case slices.Contains(PathFlags, name) && i+1 < len(mapped):
+			i++
+			mapped[i] = fn(mapped[i])
+		case name == "component" && hasValue:
+			mapped[i] = mapped[i][:len(mapped[i])-len(value)] + mapComponentPaths(value, fn)
+		case name == "component" && i+1 < len(mapped):
+			i++
+			mapped[i] = mapComponentPaths(mapped[i], fn)
+		}
+	}
+	return mapped
+}
+
+// mapComponentPaths replaces the paths of the options of a component
+// specification name=option,option,...
+func mapComponentPaths(spec string, fn func(string) string) string {
+	name, options, _ := strings.Cut(spec, "=")
+	list := strings.Split(options, ",")
+	for i, option := range list {
+		if key, value, ok := strings.Cut(option, "="); ok && slices.Contains(PathFlags, key) {
+			list[i] = key + "=" + fn(value)
+		}
+	}
+	return name + "=" + strings.Join(list, ",")
+}
+
+// ReadLock reads the lock file of the chart.
+func ReadLock(fs interfaces.FileSystem, chartPath string) (*Lock, error) {
+	path := filepath.Join(chartPath, LockFile)
+	content, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewFileSystemError("read-lock",
+			"failed to read the lock file, the chart was not generated by a version recording its arguments "+
+				"(the charts of import, import-kustomize and from-compose are not upgraded)", err).
+			WithFile(path)
+	}
+	lock := &Lock{}
+	if err := yaml.Unmarshal(content, lock); err != nil {
+		return nil, errors.NewValidationError("read-lock", "invalid lock file: "+err.Error()).WithFile(path)
+	}
+	if lock.Version == "" {
+		return nil, errors.NewValidationError("read-lock", "the lock file has no version").WithFile(path)
+	}
+	return lock, nil
+}
+
+// WriteLock writes the lock file of the chart.
+func WriteLock(fs interfaces.FileSystem, chartPath string, lock Lock) error {
+	path := filepath.Join(chartPath, LockFile)
+	var content bytes.Buffer
+	content.WriteString(lockHeader)
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(lock); err != nil {
+		return errors.WrapError(err, errors.ValidationError, "write-lock", "failed to encode the lock file")
+	}
+	const filePerm = 0644
+	if err := fs.WriteFile(path, content.Bytes(), filePerm); err != nil {
+		return errors.NewFileSystemError("write-lock", "failed to write the lock file", err).WithFile(path)
+	}
+	return nil
+}
+
+// Status is the outcome of the upgrade of a file.
+type Status string
+
+const (
+	// Added is a file added by the new version.
+	Added Status = "added"
+	// Updated is a file unchanged in the chart, replaced by the new version.
+	Updated Status = "updated"
+	// Merged is a file whose changes in the chart and in the new version are
+	// merged.
+	Merged Status = "merged"
+	// Removed is a file unchanged in the chart, removed by the new version.
+	Removed Status = "removed"
+	// Conflict is a file whose conflicting changes are written between
+	// conflict markers.
+	Conflict Status = "conflict"
+	// Kept is a file changed in the chart and removed by the new version, it
+	// is kept.
+	Kept Status = "kept"
+	// Deleted is a file deleted from the chart and changed by the new
+	// version, it stays deleted.
+	Deleted Status = "deleted"
+)
+
+// Change is the upgrade of a file of the chart.
+type Change struct {
+	Path   string
+	Status Status
+}
+
+// String returns the status and the path of the file.
+func (c Change) String() string {
+	return string(c.Status) + " " + c.Path
+}
+
+// Merge merges the changes from the base chart (basePath) to the new chart
+// (newPath) into the chart, newLabel names the new version in the conflict
+// markers. It returns the changes of the files in the order of their path.
+func Merge(fs interfaces.FileSystem, chartPath, basePath, newPath, newLabel string) ([]Change, error) {
+	chart, err := readFiles(fs, chartPath)
+	if err != nil {
+		return nil, err
+	}
+	base, err := readFiles(fs, basePath)
+	if err != nil {
+		return nil, err
+	}
+	generated, err := readFiles(fs, newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, files := range []map[string]string{chart, base, generated} {
+		for path := range files {
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []Change
+	const filePerm = 0644
+	for _, path := range paths {
+		current, inChart := chart[path]
+		old, inBase := base[path]
+		updated, inNew := generated[path]
+		target := filepath.Join(chartPath, path)
+
+		var status Status
+		content := updated
+		switch {
+		case path == LockFile, updated == current && inNew == inChart, updated == old && inNew == inBase:
+			// unchanged by the new version, or already up to date
+			continue
+		case !inNew:
+			if current != old {
+				changes = append(changes, Change{Path: path, Status: Kept})
+				continue
+			}
+			if err := fs.Remove(target); err != nil {
+				return nil, errors.NewFileSystemError("upgrade", "failed to remove file", err).WithFile(target)
+			}
+			changes = append(changes, Change{Path: path, Status: Removed})
+			continue
+		case !inChart && inBase:
+			changes = append(changes, Change{Path: path, Status: Deleted})
+			continue
+		case !inChart:
+			status = Added
+		case current == old:
+			status = Updated
+		default:
+			var conflict bool
+			content, conflict = Merge3(old, current, updated, "chart", newLabel)
+			status = Merged
+			if conflict {
+				status = Conflict
+			}
+		}
+
+		if err := fs.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return nil, errors.NewFileSystemError("upgrade", "failed to create directory", err).WithFile(target)
+		}
+		if err := fs.WriteFile(target, []byte(content), filePerm); err != nil {
+			return nil, errors.NewFileSystemError("upgrade", "failed to write file", err).WithFile(target)
+		}
+		changes = append(changes, Change{Path: path, Status: status})
+	}
+	return changes, nil
+}
+
+// readFiles returns the contents of the files of a directory by their path
+// relative to it.
+func readFiles(fs interfaces.FileSystem, dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := fs.ReadFile(path)
+		if err != nil {
+			return errors.NewFileSystemError("upgrade", "failed to read file", err).WithFile(path)
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		if chartErr, ok := err.(*errors.ChartError); ok {
+			return nil, chartErr
+		}
+		return nil, errors.NewFileSystemError("upgrade", "failed to read directory", err).WithFile(dir)
+	}
+	return files, nil
+}
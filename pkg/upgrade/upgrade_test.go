@@ -0,0 +1,256 @@
+package upgrade
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/helmchart-helper/pkg/mocks"
+)
+
+func TestNewLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"path flag", []string{"-n", "web", "-o", "charts/web", "-deploy"}, []string{"-n", "web", "-deploy"}},
+		{"path flag with value", []string{"-o=charts/web", "-n", "web"}, []string{"-n", "web"}},
+		{"double dash", []string{"--o", "web", "-n", "web"}, []string{"-n", "web"}},
+		{"no arguments", nil, []string{}},
+		{
+			"paths relative to the chart",
+			[]string{"-n", "web", "-config-from-file", "/work/app.conf", "--from-dockerfile=/work/Dockerfile", "-env-profile", "/work/charts/web/envs.yaml"},
+			[]string{"-n", "web", "-config-from-file", "../../app.conf", "--from-dockerfile=../../Dockerfile", "-env-profile", "envs.yaml"},
+		},
+		{
+			"paths of the components",
+			[]string{"-n", "shop", "-component", "web=deploy,config-from-dir=/work/conf,svc", "-component=api=from-dockerfile=/api/Dockerfile"},
+			[]string{"-n", "shop", "-component", "web=deploy,config-from-dir=../../conf,svc", "-component=api=from-dockerfile=../../../api/Dockerfile"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock, err := NewLock("v1.2.0", "", "/work/charts/web", tt.args)
+			if err != nil {
+				t.Fatalf("NewLock() error = %v", err)
+			}
+			if !reflect.DeepEqual(lock.Args, tt.expected) {
+				t.Errorf("args = %q, want %q", lock.Args, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLock_ResolveArgs(t *testing.T) {
+	lock := Lock{Args: []string{
+		"-n", "web", "-config-from-file", "../../app.conf", "-from-dockerfile=/api/Dockerfile",
+		"-component", "web=deploy,env-profile=envs.yaml",
+	}}
+	expected := []string{
+		"-n", "web", "-config-from-file", "/work/app.conf", "-from-dockerfile=/api/Dockerfile",
+		"-component", "web=deploy,env-profile=/work/charts/web/envs.yaml",
+	}
+	if got := lock.ResolveArgs("/work/charts/web"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("ResolveArgs() = %q, want %q", got, expected)
+	}
+}
+
+func TestLock(t *testing.T) {
+	fs := mocks.NewMockFileSystem()
+	lock, err := NewLock("v1.2.0", "umbrella", "shop", []string{"-n", "shop", "-o", "shop", "-component", "web=deploy,svc"})
+	if err != nil {
+		t.Fatalf("NewLock() error = %v", err)
+	}
+	if err := WriteLock(fs, "shop", lock); err != nil {
+		t.Fatalf("WriteLock() error = %v", err)
+	}
+	const expected = `# Generated by helmchart-helper: the version and the arguments of the
+# generation of the chart, helmchart-helper upgrade regenerates the chart from
+# them to re-apply newer templates.
+version: v1.2.0
+command: umbrella
+args:
+  - -n
+  - shop
+  - -component
+  - web=deploy,svc
+`
+	if got := string(fs.Files["shop/.helmchart-helper.yaml"]); got != expected {
+		t.Errorf("lock file = %q, want %q", got, expected)
+	}
+
+	read, err := ReadLock(fs, "shop")
+	if err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+	if !reflect.DeepEqual(*read, lock) {
+		t.Errorf("ReadLock() = %+v, want %+v", *read, lock)
+	}
+
+	fs.Files["web/.helmchart-helper.yaml"] = []byte("args: [-n, web]\n")
+	if _, err := ReadLock(fs, "web"); err == nil || !strings.Contains(err.Error(), "the lock file has no version") {
+		t.Errorf("ReadLock() error = %v, want error containing %q", err, "the lock file has no version")
+	}
+	if _, err := ReadLock(fs, "api"); err == nil || !strings.Contains(err.Error(), "failed to read the lock file") {
+		t.Errorf("ReadLock() error = %v, want error containing %q", err, "failed to read the lock file")
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	const base = "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name     string
+		base     string
+		local    string
+		other    string
+		expected string
+		conflict bool
+	}{
+		{
+			name:     "changes of both sides",
+			base:     base,
+			local:    "a\nB\nc\nd\ne\n",
+			other:    "a\nb\nc\nD\ne\nf\n",
+			expected: "a\nB\nc\nD\ne\nf\n",
+		},
+		{
+			name:     "same change",
+			base:     base,
+			local:    "a\nB\nc\nd\ne\n",
+			other:    "a\nB\nc\nd\ne\n",
+			expected: "a\nB\nc\nd\ne\n",
+		},
+		{
+			name:     "deleted and inserted lines",
+			base:     base,
+			local:    "a\nc\nd\ne\n",
+			other:    "z\na\nb\nc\nd\ne\n",
+			expected: "z\na\nc\nd\ne\n",
+		},
+		{
+			name:     "conflict",
+			base:     base,
+			local:    "a\nb\nC\nd\ne\n",
+			other:    "a\nb\nc2\nd\ne\n",
+			expected: "a\nb\n<<<<<<< chart\nC\n=======\nc2\n>>>>>>> new\nd\ne\n",
+			conflict: true,
+		},
+		{
+			name:     "conflict at the end without line break",
+			base:     "a\nb",
+			local:    "a\nB",
+			other:    "a\nb2",
+			expected: "a\n<<<<<<< chart\nB\n=======\nb2\n>>>>>>> new\n",
+			conflict: true,
+		},
+		{
+			name:     "no line break at the end",
+			base:     "a\nb",
+			local:    "A\nb",
+			other:    "a\nb\nc",
+			expected: "A\nb\nc",
+		},
+		{
+			name:     "added by both sides",
+			base:     "",
+			local:    "a\n",
+			other:    "b\n",
+			expected: "<<<<<<< chart\na\n=======\nb\n>>>>>>> new\n",
+			conflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflict := Merge3(tt.base, tt.local, tt.other, "chart", "new")
+			if got != tt.expected || conflict != tt.conflict {
+				t.Errorf("Merge3() = %q, %v, want %q, %v", got, conflict, tt.expected, tt.conflict)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	fs := mocks.NewMockFileSystem()
+	files := map[string]map[string]string{
+		"chart": {
+			".helmchart-helper.yaml":   "version: v1.0.0\n",
+			"values.yaml":              "replicaCount: 3\nimage: nginx\n",
+			"templates/hpa.yaml":       "apiVersion: autoscaling/v2beta2\n",
+			"templates/old.yaml":       "old\n",
+			"templates/edited.yaml":    "edited\n",
+			"templates/conflict.yaml":  "local\n",
+			"templates/extra.yaml":     "user file\n",
+			"templates/unchanged.yaml": "same\n",
+		},
+		"base": {
+			"values.yaml":              "replicaCount: 1\nimage: nginx\n",
+			"templates/hpa.yaml":       "apiVersion: autoscaling/v2beta2\n",
+			"templates/old.yaml":       "old\n",
+			"templates/edited.yaml":    "generated\n",
+			"templates/conflict.yaml":  "base\n",
+			"templates/deleted.yaml":   "deleted\n",
+			"templates/unchanged.yaml": "same\n",
+		},
+		"new": {
+			"values.yaml":              "replicaCount: 1\nimage: nginx\nresources: {}\n",
+			"templates/hpa.yaml":       "apiVersion: autoscaling/v2\n",
+			"templates/pdb.yaml":       "pdb\n",
+			"templates/conflict.yaml":  "new\n",
+			"templates/deleted.yaml":   "deleted v2\n",
+			"templates/unchanged.yaml": "same\n",
+		},
+	}
+	for dir, contents := range files {
+		for path, content := range contents {
+			fs.Files[dir+"/"+path] = []byte(content)
+		}
+	}
+
+	changes, err := Merge(fs, "chart", "base", "new", "new")
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	expected := []Change{
+		{"templates/conflict.yaml", Conflict},
+		{"templates/deleted.yaml", Deleted},
+		{"templates/edited.yaml", Kept},
+		{"templates/hpa.yaml", Updated},
+		{"templates/old.yaml", Removed},
+		{"templates/pdb.yaml", Added},
+		{"values.yaml", Merged},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Merge() = %v, want %v", changes, expected)
+	}
+
+	expectedFiles := map[string]string{
+		"chart/.helmchart-helper.yaml":   "version: v1.0.0\n",
+		"chart/values.yaml":              "replicaCount: 3\nimage: nginx\nresources: {}\n",
+		"chart/templates/hpa.yaml":       "apiVersion: autoscaling/v2\n",
+		"chart/templates/pdb.yaml":       "pdb\n",
+		"chart/templates/edited.yaml":    "edited\n",
+		"chart/templates/conflict.yaml":  "<<<<<<< chart\nlocal\n=======\nnew\n>>>>>>> new\n",
+		"chart/templates/extra.yaml":     "user file\n",
+		"chart/templates/unchanged.yaml": "same\n",
+	}
+	for path, content := range fs.Files {
+		if !strings.HasPrefix(path, "chart/") {
+			continue
+		}
+		want, ok := expectedFiles[path]
+		if !ok {
+			t.Errorf("unexpected file %s", path)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+		delete(expectedFiles, path)
+	}
+	for path := range expectedFiles {
+		t.Errorf("missing file %s", path)
+	}
+}